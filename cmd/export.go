@@ -0,0 +1,37 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"taos_importer/internal/exporter"
+	"time"
+)
+
+// exportData 按 [stable] 的配置把超级表导出为 csv，导出的目录可以再用 import 导入
+func exportData(ctx context.Context, configFile string, options exporter.Options) {
+	log.Println("## start to export data. config file is ", configFile)
+	conf := readConfig(configFile)
+	dt := openDatabaseAndTable(conf)
+	defer func() { _ = dt.Close() }()
+
+	e, err := exporter.NewCsvExporter(conf, dt, options)
+	if err != nil {
+		log.Printf("## %v", err)
+		os.Exit(1)
+	}
+	start := time.Now()
+	results, err := e.Export(ctx)
+	if err != nil {
+		log.Printf("## export stable [%s] error %v", conf.STable.Name, err)
+		os.Exit(1)
+	}
+	for _, msg := range results {
+		log.Println(msg)
+	}
+	log.Printf("## exporting data finished. tables-[%d] failed-[%d] rows-[%d] spend-[%d] ms",
+		e.Tables.Load(), e.Failed.Load(), e.Rows.Load(), time.Since(start).Milliseconds())
+	if e.Failed.Load() > 0 {
+		os.Exit(1)
+	}
+}
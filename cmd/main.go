@@ -9,16 +9,22 @@ import (
 	"log"
 	"net/http"
 	_ "net/http/pprof"
+	"net/url"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"strings"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"taos_importer/internal/common"
 	"taos_importer/internal/config"
 	"taos_importer/internal/db_table"
+	"taos_importer/internal/exporter"
 	"taos_importer/internal/field"
 	"taos_importer/internal/importer"
+	"time"
 
 	"github.com/pelletier/go-toml/v2"
 )
@@ -29,6 +35,36 @@ func main() {
 	confFile := importCmd.String("conf", "", "config file path. Required!")
 	autoCreate := importCmd.Bool("auto-create", true, "auto create database, stable, tables. Optional, default is true")
 	outputFile := importCmd.String("output-file", "", "output file path. Optional, default is local path")
+	target := importCmd.String("target", targetTDengine, "import target, tdengine, sqlfile or csvfile. csvfile writes one csv file per child table, see --load. Optional, default is tdengine")
+	outDir := importCmd.String("out", "", "output dir of sql files or csv files. Required when target is sqlfile or csvfile")
+	maxFileSize := importCmd.Int("max-file-size", 100, "max size of one sql file in MB. Optional, default is 100")
+	exportCmd := flag.NewFlagSet("export", flag.ExitOnError)
+	exportConf := exportCmd.String("conf", "", "config file path. Required!")
+	exportOut := exportCmd.String("out", "", "output dir, tags are written to tags.csv and data to data/<table>.csv. Required!")
+	from := exportCmd.String("from", "", "export rows with timestamp >= from, ISO-8601 or integer timestamp. Optional")
+	to := exportCmd.String("to", "", "export rows with timestamp < to, ISO-8601 or integer timestamp. Optional")
+	tagFilter := exportCmd.String("filter", "", "filter expression of tags, tables with false result are not exported. Optional")
+
+	verifyCmd := flag.NewFlagSet("verify", flag.ExitOnError)
+	verifyConf := verifyCmd.String("conf", "", "config file path. Required!")
+	verifySum := verifyCmd.String("sum", "", "columns to compare sum, separated by comma. Optional")
+
+	verify := importCmd.Bool("verify", false, "verify data in tdengine with source files after import, only for target tdengine. Optional, default is false")
+	sum := importCmd.String("sum", "", "columns to compare sum when verify, separated by comma. Optional")
+	load := importCmd.Bool("load", false, "load csv files by insert into ... file after export, only for target csvfile with protocol native. Optional, default is false")
+	watch := importCmd.Bool("watch", false, "keep running and import new files in data_dir, only for target tdengine. Optional, default is false")
+	watchInterval := importCmd.Duration("watch-interval", 10*time.Second, "interval to scan data_dir, files unchanged between two scans are imported. Optional, default is 10s")
+	stateFile := importCmd.String("state", "", "file to record imported files when watch. Optional, default is <data_dir>/.taos_importer_state.json")
+	doneDir := importCmd.String("done-dir", "", "move imported files to this dir when watch, relative to data_dir. Optional")
+	failedDir := importCmd.String("failed-dir", "", "move failed files to this dir when watch, relative to data_dir. Optional")
+
+	tailCmd := flag.NewFlagSet("tail", flag.ExitOnError)
+	tailConf := tailCmd.String("conf", "", "config file path. Required!")
+	topic := tailCmd.String("topic", "", "topic on columns of the stable, created if not exists. Optional, default is importer_<db>_<stable>")
+	group := tailCmd.String("group", "", "consumer group. Optional, default is taos_importer_tail or taos_importer_mirror_<mirror db>")
+	offset := tailCmd.String("offset", "", "where a new group starts, earliest or latest. Optional, default is latest, earliest when mirror")
+	interval := tailCmd.Duration("interval", 10*time.Second, "interval to report rows per table. Optional, default is 10s")
+	mirror := tailCmd.String("mirror", "", "copy consumed rows to the same stable in this database. Optional")
 
 	if len(os.Args) < 2 {
 		log.Printf("## param error %v", os.Args[1:])
@@ -43,32 +79,56 @@ func main() {
 	switch os.Args[1] {
 	case "import":
 		_ = importCmd.Parse(os.Args[2:])
-		importData(ctx, *confFile, autoCreate, outputFile)
+		importData(ctx, *confFile, autoCreate, outputFile, importTarget{name: *target, out: *outDir, maxFileSize: *maxFileSize, load: *load, verify: *verify, sums: splitColumns(*sum),
+			watch: watchOptions{enabled: *watch, interval: *watchInterval, state: *stateFile, doneDir: *doneDir, failedDir: *failedDir}})
+	case "verify":
+		_ = verifyCmd.Parse(os.Args[2:])
+		verifyCommand(ctx, *verifyConf, *verifySum)
+	case "tail":
+		_ = tailCmd.Parse(os.Args[2:])
+		tailData(ctx, *tailConf, tailOptions{topic: *topic, group: *group, offset: *offset, interval: *interval, mirror: *mirror})
+	case "export":
+		_ = exportCmd.Parse(os.Args[2:])
+		exportData(ctx, *exportConf, exporter.Options{Dir: *exportOut, From: *from, To: *to, Filter: *tagFilter})
 	default:
 		log.Printf("## unknown command %s ", os.Args[1])
 		os.Exit(1)
 	}
 }
 
-func importData(ctx context.Context, configFile string, autoCreate *bool, outputFile *string) {
-	log.Println("## start to import data. config file is ", configFile)
-	f, err := os.Open(configFile)
-	if err != nil {
-		log.Printf("## open config file [%s] error %v", configFile, err)
-		os.Exit(1)
-	}
-	defer func() { _ = f.Close() }()
-	b, err := io.ReadAll(f)
-	if err != nil {
-		log.Printf("## read config file content [%s] error %v", configFile, err)
-		os.Exit(1)
-	}
+const (
+	targetTDengine = "tdengine"
+	targetSqlFile  = "sqlfile"
+	targetCsvFile  = "csvfile"
+)
 
-	var conf config.Config
-	if err = toml.Unmarshal(b, &conf); err != nil {
-		log.Printf("## read config file [%s] fail %v", configFile, err)
-		os.Exit(1)
-	}
+// importTarget 导入的目标，sqlfile 时把建表和写入语句写到 out 目录下的 SQL 文件，
+// csvfile 时按子表写入 out 目录下的 csv 文件，load 时之后用 insert into ... file 导入。
+// tdengine 时 verify 为 true 则导入后核对数据，sums 为核对 sum 的列，watch 时持续导入新文件
+type importTarget struct {
+	name        string
+	out         string
+	maxFileSize int // MB
+	load        bool
+	verify      bool
+	sums        []string
+	watch       watchOptions
+}
+
+// tableCreator 建库、建表，DatabaseAndTable 在 TDengine 中执行，Script 写入 SQL 文件
+type tableCreator interface {
+	CreateDB(ctx context.Context, param db_table.DBParam) error
+	CreateSTable(ctx context.Context, param db_table.STableParam) error
+	CreateTables(ctx context.Context, params []db_table.TableParam) error
+	ExistingTables(ctx context.Context, db string, stable string) (map[string]map[string]string, error)
+	// Ordered 为 true 时需要按顺序逐个建表
+	Ordered() bool
+	Close() error
+}
+
+func importData(ctx context.Context, configFile string, autoCreate *bool, outputFile *string, target importTarget) {
+	log.Println("## start to import data. config file is ", configFile)
+	conf := readConfig(configFile)
 
 	if conf.Pprof {
 		go func() {
@@ -78,6 +138,8 @@ func importData(ctx context.Context, configFile string, autoCreate *bool, output
 		}()
 	}
 
+	loadLookups(conf)
+
 	if autoCreate != nil && *autoCreate {
 		conf.AutoCreate = *autoCreate
 		conf.OutputFile = *outputFile
@@ -96,29 +158,299 @@ func importData(ctx context.Context, configFile string, autoCreate *bool, output
 	logfile := bufio.NewWriter(output)
 	defer func() { _ = logfile.Flush() }()
 
-	// todo create db, stable
-	// create child table
-	tableNames := createTables(ctx, conf, conf.AutoCreate)
-	// import data
-	ch := make(chan string, 100)
-	go importDataToTable(ctx, conf, ch, tableNames)
+	// insert into ... file 只有原生连接支持
+	if target.load && (target.name != targetCsvFile || conf.TDEngine.GetProtocol() != config.ProtocolNative) {
+		log.Println("## load is only for target csvfile with protocol native")
+		os.Exit(1)
+	}
+	if target.watch.enabled && (target.name != targetTDengine || target.verify) {
+		log.Println("## watch is only for target tdengine without verify")
+		os.Exit(1)
+	}
 
-	for msg := range ch {
+	var creator tableCreator
+	var sink importer.Sink
+	switch target.name {
+	case targetTDengine:
+		creator, sink = openTDengine(conf)
+	case targetSqlFile:
+		creator, sink = openSqlFile(conf, target)
+		// 按文件顺序逐个导入，同样的输入得到同样的 SQL 文件
+		conf.DealOneTime = 1
+	case targetCsvFile:
+		creator, sink = openCsvFile(conf, target)
+		conf.DealOneTime = 1
+	default:
+		log.Printf("## unknown target %s", target.name)
+		os.Exit(1)
+	}
+	if conf.AutoCreate {
+		createDB(ctx, conf, creator)
+		createSTables(ctx, conf, creator)
+	}
+
+	// on_existing 需要查询、删除子表已有的数据，只用于 tdengine
+	var existing *importer.Existing
+	if target.name == targetTDengine {
+		dt := openDatabaseAndTable(conf)
+		defer func() { _ = dt.Close() }()
+		if existing, err = importer.NewExisting(conf, dt); err != nil {
+			log.Printf("## %v", err)
+			os.Exit(1)
+		}
+		if p := existing.Policy(); p == importer.OnExistingReplaceRange || p == importer.OnExistingReplaceTable {
+			log.Printf("## on_existing [%s] deletes rows before importing each file, they are not restored if the import fails", p)
+		}
+	} else if len(conf.STable.OnExisting) > 0 && conf.STable.OnExisting != importer.OnExistingAppend {
+		log.Printf("## on_existing [%s] is only for target tdengine", conf.STable.OnExisting)
+		os.Exit(1)
+	}
+
+	var watchErr error
+	if target.watch.enabled {
+		// 每批新文件导入前读取新的和变化的 tag 文件建表
+		watchErr = watchData(ctx, conf, creator, sink, existing, logfile, target.watch)
+	} else {
+		// create child table
+		tableNames := createTables(ctx, conf, creator, conf.AutoCreate)
+		if err = creator.Close(); err != nil {
+			log.Printf("## close table creator error %v", err)
+			os.Exit(1)
+		}
+		// import data
+		ch := make(chan string, 100)
+		go importDataToTable(ctx, conf, sink, existing, ch, tableNames)
+
+		for msg := range ch {
+			_, _ = logfile.WriteString(msg)
+			_, _ = logfile.WriteString("\n")
+		}
+	}
+	if err = sink.Close(); err != nil {
+		log.Printf("## close %s error %v", target.name, err)
+		os.Exit(1)
+	}
+	switch s := sink.(type) {
+	case *importer.Pool:
+		_, _ = logfile.WriteString(s.Stats().String())
+	case *importer.SqlFile:
+		_, _ = logfile.WriteString(fmt.Sprintf("## sql files-%v statements-[%d]", s.Files(), s.Statements()))
+	case *importer.CsvFile:
+		_, _ = logfile.WriteString(fmt.Sprintf("## csv files-[%d] manifest-[%s]", len(s.Files()), s.Manifest()))
+	}
+	_, _ = logfile.WriteString("\n")
+	if s, ok := sink.(*importer.CsvFile); ok {
+		for _, msg := range loadCsvFiles(ctx, conf, target, s.Files()) {
+			_, _ = logfile.WriteString(msg)
+			_, _ = logfile.WriteString("\n")
+		}
+	}
+	if target.verify && target.name == targetTDengine {
+		results, ok := verifyData(ctx, conf, target.sums)
+		for _, msg := range results {
+			_, _ = logfile.WriteString(msg)
+			_, _ = logfile.WriteString("\n")
+		}
+		log.Println(results[len(results)-1])
+		if !ok {
+			log.Printf("## verify found mismatched tables, see %s", conf.OutputFile)
+		}
+	}
+	for _, msg := range field.DefaultLookups.MissingReport() {
 		_, _ = logfile.WriteString(msg)
 		_, _ = logfile.WriteString("\n")
 	}
 
+	if watchErr != nil {
+		log.Printf("## stop watching. %v", watchErr)
+		_ = logfile.Flush()
+		os.Exit(1)
+	}
 	log.Println("## importing data finished. config file is ", configFile)
 }
 
-// nolint
-func createDB(ctx context.Context, conf config.Config) {
-	dbUri := getDBUri(conf)
-	dt, err := db_table.NewDatabaseAndTable(dbUri)
+func readConfig(configFile string) config.Config {
+	f, err := os.Open(configFile)
+	if err != nil {
+		log.Printf("## open config file [%s] error %v", configFile, err)
+		os.Exit(1)
+	}
+	defer func() { _ = f.Close() }()
+	b, err := io.ReadAll(f)
+	if err != nil {
+		log.Printf("## read config file content [%s] error %v", configFile, err)
+		os.Exit(1)
+	}
+
+	var conf config.Config
+	if err = toml.Unmarshal(b, &conf); err != nil {
+		log.Printf("## read config file [%s] fail %v", configFile, err)
+		os.Exit(1)
+	}
+	return conf
+}
+
+// openTDengine 建表和写入各自共用一个连接池
+func openTDengine(conf config.Config) (*db_table.DatabaseAndTable, *importer.Pool) {
+	dt := openDatabaseAndTable(conf)
+	pool, err := importer.NewPool(conf)
+	if err != nil {
+		log.Printf("## connect to tdengine error %v", err)
+		os.Exit(1)
+	}
+	return dt, pool
+}
+
+// openSqlFile 建库、建表语句写入 out/schema.sql，数据写入 out/data_0001.sql ...
+func openSqlFile(conf config.Config, target importTarget) (*db_table.Script, *importer.SqlFile) {
+	script := openScript(conf, target.out)
+	return script, importer.NewSqlFile(target.out, int64(target.maxFileSize)<<20, conf.TDEngine.GetMaxSqlLength())
+}
+
+// openCsvFile 数据按子表写入 out/<库名>/<子表名>.csv。load 时在 TDengine 中建库、建表，否则建表语句写入 out/schema.sql
+func openCsvFile(conf config.Config, target importTarget) (tableCreator, *importer.CsvFile) {
+	var creator tableCreator
+	if target.load {
+		if err := os.MkdirAll(target.out, 0755); err != nil {
+			log.Printf("## create dir [%s] error %v", target.out, err)
+			os.Exit(1)
+		}
+		creator = openDatabaseAndTable(conf)
+	} else {
+		creator = openScript(conf, target.out)
+	}
+	sink, err := importer.NewCsvFile(target.out)
+	if err != nil {
+		log.Printf("## open dir [%s] error %v", target.out, err)
+		os.Exit(1)
+	}
+	return creator, sink
+}
+
+// openScript 创建 out 目录，建库、建表语句写入 out/schema.sql
+func openScript(conf config.Config, out string) *db_table.Script {
+	if len(out) == 0 {
+		log.Println("## param error, out is null")
+		os.Exit(1)
+	}
+	if err := os.MkdirAll(out, 0755); err != nil {
+		log.Printf("## create dir [%s] error %v", out, err)
+		os.Exit(1)
+	}
+	script, err := db_table.NewScript(filepath.Join(out, "schema.sql"))
+	if err != nil {
+		log.Printf("## create schema file error %v", err)
+		os.Exit(1)
+	}
+	script.MaxSqlLength = conf.TDEngine.GetMaxSqlLength()
+	return script
+}
+
+// loadCsvFiles 把 insert into ... file 语句写入 out/load.sql，load 时用 concurrent 个连接执行，返回每个文件的结果
+func loadCsvFiles(ctx context.Context, conf config.Config, target importTarget, files []importer.CsvTableFile) []string {
+	script, err := db_table.NewScript(filepath.Join(target.out, "load.sql"))
+	if err != nil {
+		log.Printf("## create load file error %v", err)
+		os.Exit(1)
+	}
+	for _, f := range files {
+		if err = script.InsertFile(ctx, f.DB, f.Table, f.File); err != nil {
+			log.Printf("## write load sql of [%s] error %v", f.File, err)
+			os.Exit(1)
+		}
+	}
+	if err = script.Close(); err != nil {
+		log.Printf("## write load file error %v", err)
+		os.Exit(1)
+	}
+	if !target.load {
+		return nil
+	}
+
+	dt := openDatabaseAndTable(conf)
+	defer func() { _ = dt.Close() }()
+	start := time.Now()
+	ch := make(chan int)
+	results := make([]string, len(files))
+	var failed atomic.Int64
+	var wait sync.WaitGroup
+	go func() {
+		defer close(ch)
+		for i := range files {
+			ch <- i
+		}
+	}()
+	workers := conf.Concurrent
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range ch {
+				f, begin := files[i], time.Now()
+				if err := dt.InsertFile(ctx, f.DB, f.Table, f.File); err != nil {
+					failed.Add(1)
+					results[i] = fmt.Sprintf("## load file [%s] to table [%s] error %v", f.File, f.Table, err)
+					continue
+				}
+				results[i] = fmt.Sprintf("## load file [%s] to table [%s] finished. rows-[%d] spend-[%d] ms", f.File, f.Table, f.Rows, time.Since(begin).Milliseconds())
+			}
+		}()
+	}
+	wait.Wait()
+	log.Printf("## loaded csv files-[%d] failed-[%d] spend-[%d] ms", len(files), failed.Load(), time.Since(start).Milliseconds())
+	return results
+}
+
+func openDatabaseAndTable(conf config.Config) *db_table.DatabaseAndTable {
+	driver, dbUri := getDBUri(conf)
+	dt, err := db_table.NewDatabaseAndTable(driver, dbUri)
 	if err != nil {
-		log.Printf("## connect to database %s error %v ", dbUri, err)
+		log.Printf("## connect to database %s error %v", dbUri, err)
 		os.Exit(1)
 	}
+	dt.MaxSqlLength = conf.TDEngine.GetMaxSqlLength()
+	if len(conf.TDEngine.Timezone) > 0 {
+		if dt.Location, err = common.Location(conf.TDEngine.Timezone); err != nil {
+			log.Printf("## config error, timezone %s %v", conf.TDEngine.Timezone, err)
+			os.Exit(1)
+		}
+	}
+	return dt
+}
+
+func loadLookups(conf config.Config) {
+	for _, l := range conf.Lookups {
+		source := field.LookupSource{Name: l.Name, File: l.File, Format: l.Format, Key: l.Key, Value: l.Value, Separator: l.Separator}
+		if len(conf.DataDir) > 0 && !filepath.IsAbs(source.File) {
+			source.File = path.Join(conf.DataDir, source.File)
+		}
+		if err := field.DefaultLookups.Load(source); err != nil {
+			log.Printf("## %v", err)
+			os.Exit(1)
+		}
+	}
+	if len(conf.Lookups) == 0 {
+		return
+	}
+
+	// kill -HUP 重新加载字典
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := field.DefaultLookups.Reload(); err != nil {
+				log.Printf("## reload lookups error %v", err)
+				continue
+			}
+			log.Println("## lookups reloaded")
+		}
+	}()
+}
+
+func createDB(ctx context.Context, conf config.Config, dt tableCreator) {
 	var param db_table.DBParam
 	param.DBName = conf.DB.Name
 	if conf.DB.Buffer > 0 {
@@ -142,100 +474,258 @@ func createDB(ctx context.Context, conf config.Config) {
 	if conf.DB.VGroups > 0 {
 		param.VGroups = conf.DB.VGroups
 	}
-	if err = dt.CreateDB(ctx, param); err != nil {
+	if err := dt.CreateDB(ctx, param); err != nil {
 		log.Printf("## create database %s error %v", conf.DB.Name, err)
 		os.Exit(1)
 	}
 }
 
-// nolint
-func createSTable(ctx context.Context, conf config.Config) {
-	dbUri := getDBUri(conf)
-	dt, err := db_table.NewDatabaseAndTable(dbUri)
-	if err != nil {
-		log.Printf("## connect to database %s error %v", dbUri, err)
-		os.Exit(1)
+// createSTables 按 [stable] 的列和 tags 创建超级表，配置了聚合时聚合超级表使用相同的 tags
+func createSTables(ctx context.Context, conf config.Config, dt tableCreator) {
+	tags := make([]db_table.TableColumn, 0, len(conf.STable.Tags))
+	for _, tag := range conf.STable.Tags {
+		tags = append(tags, db_table.TableColumn{ColumnName: tag.Field, ColumnType: tag.Type})
+	}
+	params := []db_table.STableParam{{DBName: conf.DB.Name, STableName: conf.STable.Name, Columns: tableColumns(conf.STable.Columns), Tags: tags}}
+	if len(conf.Aggregate.STable) > 0 {
+		params = append(params, db_table.STableParam{
+			DBName:     conf.DB.Name,
+			STableName: conf.Aggregate.STable,
+			Columns:    tableColumns(importer.AggregateColumns(conf.Aggregate)),
+			Tags:       tags,
+		})
 	}
-	sql := "" // todo
-	if err = dt.CreateSTableBySql(ctx, conf.DB.Name, sql); err != nil {
-		log.Printf("## create stable by sql %s error %v", sql, err)
-		os.Exit(1)
+	for _, param := range params {
+		if err := dt.CreateSTable(ctx, param); err != nil {
+			log.Printf("## create stable %s error %v", param.STableName, err)
+			os.Exit(1)
+		}
 	}
 }
 
-func createTables(ctx context.Context, conf config.Config, autoCreate bool) (tables map[string]struct{}) {
-	tableFiles, err := getFiles(conf.TagsDir, conf.TagsFiles, conf.TagsFileSuffix, "", nil)
+func tableColumns(columns []config.Column) []db_table.TableColumn {
+	result := make([]db_table.TableColumn, 0, len(columns))
+	for _, column := range columns {
+		result = append(result, db_table.TableColumn{ColumnName: column.Field, ColumnType: column.Type})
+	}
+	return result
+}
+
+func createTables(ctx context.Context, conf config.Config, dt tableCreator, autoCreate bool) (tables map[string]struct{}) {
+	registry := newNameRegistry(conf)
+	tables = make(map[string]struct{}, 100)
+	createTablesFrom(ctx, conf, dt, autoCreate, tagFiles(conf), registry, tables)
+	for _, msg := range registry.Conflicts() {
+		log.Println(msg)
+	}
+	return
+}
+
+// tagFiles 返回所有 tag 文件，按配置和目录中的顺序
+func tagFiles(conf config.Config) []string {
+	tableFiles, err := getFiles(conf.TagsDir, conf.TagsFiles, conf.TagsFileSuffix, config.STable{}, nil)
 	if err != nil {
 		log.Printf("## get tag file error %v", err)
 		os.Exit(1)
 	}
+	var files []string
+	for file := range tableFiles {
+		files = append(files, file)
+	}
+	return files
+}
 
-	dbUri := getDBUri(conf)
-	dt, err := db_table.NewDatabaseAndTable(dbUri)
+func newNameRegistry(conf config.Config) *db_table.NameRegistry {
+	registry, err := db_table.NewNameRegistry(conf.STable.NameCollision)
 	if err != nil {
-		log.Printf("## connect to database %s error %v", dbUri, err)
+		log.Printf("## %v", err)
 		os.Exit(1)
 	}
+	// 数据文件按文件名写入子表，加了后缀的子表没有对应的数据文件
+	if conf.STable.NameCollision == db_table.CollisionSuffix && len(conf.STable.Explode.Table) == 0 {
+		log.Printf("## config error, name_collision %s needs stable.explode.table, data files can't be routed to suffixed tables by file name", db_table.CollisionSuffix)
+		os.Exit(1)
+	}
+	return registry
+}
 
-	tbNameCh := make(chan string, 10)
-	tables = make(map[string]struct{}, 100)
-	var wait sync.WaitGroup
+// createTablesFrom 读取 files 中的 tag 文件，在 registry 中登记子表名后建表，登记的子表加入 tables
+func createTablesFrom(ctx context.Context, conf config.Config, dt tableCreator, autoCreate bool, files []string, registry *db_table.NameRegistry, tables map[string]struct{}) {
+	var err error
+	// 已存在的子表不再创建，tags 与 tag 文件不同的只报告
+	existing := make(map[string]map[string]map[string]string)
+	if autoCreate {
+		for _, stable := range []string{conf.STable.Name, conf.Aggregate.STable} {
+			if len(stable) == 0 {
+				continue
+			}
+			if existing[stable], err = dt.ExistingTables(ctx, conf.DB.Name, stable); err != nil {
+				log.Printf("## query existing tables error %v", err)
+				os.Exit(1)
+			}
+			log.Printf("## stable [%s] has %d tables", stable, len(existing[stable]))
+		}
+	}
+	var skipped, changed int
+	create := func(param db_table.TableParam, ch chan db_table.TableParam) {
+		stored, ok := existing[param.STableName][param.TableName]
+		if !ok {
+			ch <- param
+			return
+		}
+		skipped++
+		if diff := db_table.TagDiff(param, stored); len(diff) > 0 {
+			changed++
+			for _, msg := range diff {
+				log.Println(msg)
+			}
+		}
+	}
 
+	// 并发读取 tag 文件，按文件和行的顺序登记子表名，冲突时保留和加后缀的行是确定的
+	parsed := make([]chan []db_table.TableParam, len(files))
+	for i := range parsed {
+		parsed[i] = make(chan []db_table.TableParam, 1)
+	}
+	next := make(chan int)
+	go func() {
+		defer close(next)
+		for i := range files {
+			next <- i
+		}
+	}()
 	for i := 0; i < 10; i++ {
-		wait.Add(1)
-
-		go func(files chan string, w *sync.WaitGroup) {
-			defer w.Done()
-			for file := range files {
-				ch, err := common.ReadCsv(file)
-				if err != nil {
-					log.Println("## read tag fail error", err)
-					os.Exit(1)
-				}
-
-				for line := range ch {
-					param, tbCode := tableParam(conf.DB.Name, conf.STable.Name, conf.STable.ChildTableName, line, conf.STable.Tags)
-					tbNameCh <- tbCode
-
-					if !autoCreate {
-						continue
-					}
-					if err = dt.CreateTable(ctx, param); err != nil {
-						log.Printf("## create table [%s] error %v \n", param.TableName, err)
-						os.Exit(1)
-					}
-				}
+		go func() {
+			for i := range next {
+				parsed[i] <- tagFileParams(conf, files[i])
 			}
-		}(tableFiles, &wait)
+		}()
 	}
 
+	paramCh := make(chan db_table.TableParam, 100)
+	var created sync.WaitGroup
+	created.Add(1)
 	go func() {
-		defer close(tbNameCh)
-		wait.Wait()
+		defer created.Done()
+		// 写入脚本时按 tag 文件的顺序建表，输出是确定的
+		workers := 10
+		if dt.Ordered() {
+			workers = 1
+		}
+		batchCreateTables(ctx, dt, paramCh, conf.CreateBatch, workers)
 	}()
 
-	for tbName := range tbNameCh {
-		tables[tbName] = struct{}{}
+	for i, file := range files {
+		for num, param := range <-parsed[i] {
+			name, err := registry.Register(param.TableName, param.TagValues, fmt.Sprintf("%s:%d", file, num+1))
+			if err != nil {
+				log.Printf("## %v", err)
+				os.Exit(1)
+			}
+			if len(name) == 0 {
+				continue
+			}
+			param.TableName = name
+			tables[name] = struct{}{}
+
+			if !autoCreate {
+				continue
+			}
+			create(param, paramCh)
+			if len(conf.Aggregate.STable) == 0 {
+				continue
+			}
+			// 聚合子表使用与原始子表相同的 tags
+			param.STableName = conf.Aggregate.STable
+			param.TableName += importer.AggregateTableSuffix(conf.Aggregate)
+			create(param, paramCh)
+		}
+	}
+	close(paramCh)
+	created.Wait()
+	if autoCreate {
+		log.Printf("## skip existing tables-[%d], tables with different tags-[%d]", skipped, changed)
 	}
+}
 
-	return
+// tagFileParams 读取一个 tag 文件，按行的顺序返回每行的子表
+func tagFileParams(conf config.Config, file string) []db_table.TableParam {
+	ch, err := common.ReadCsv(file)
+	if err != nil {
+		log.Println("## read tag fail error", err)
+		os.Exit(1)
+	}
+
+	var params []db_table.TableParam
+	for line := range ch {
+		params = append(params, tableParam(conf.DB, conf.STable, line))
+	}
+	return params
+}
+
+// batchCreateTables 每 batchSize 个子表用一条语句创建，workers 个 goroutine 并发执行，并打印进度
+func batchCreateTables(ctx context.Context, dt tableCreator, params chan db_table.TableParam, batchSize int, workers int) {
+	if batchSize <= 0 {
+		batchSize = 500
+	}
+	start := time.Now()
+	var total atomic.Int64
+
+	create := func(batch []db_table.TableParam) {
+		if err := dt.CreateTables(ctx, batch); err != nil {
+			log.Printf("## create tables error %v", err)
+			os.Exit(1)
+		}
+		log.Printf("## created tables-[%d] spend-[%d] ms", total.Add(int64(len(batch))), time.Since(start).Milliseconds())
+	}
+
+	var wait sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			batch := make([]db_table.TableParam, 0, batchSize)
+			for param := range params {
+				batch = append(batch, param)
+				if len(batch) >= batchSize {
+					create(batch)
+					batch = batch[:0]
+				}
+			}
+			if len(batch) > 0 {
+				create(batch)
+			}
+		}()
+	}
+	wait.Wait()
 }
 
-func tableParam(db, stable, tableNamePattern string, line map[string]string, tags []config.Column) (db_table.TableParam, string) {
+func tableParam(db config.Database, stable config.STable, line map[string]string) db_table.TableParam {
 	lineData := common.StrMap2AnyMap(line)
-	tableName, err := db_table.GenerateTableName(tableNamePattern, lineData)
-	if err != nil {
-		log.Printf("## get table name -[%s] error %v", tableNamePattern, err)
+	var tableName string
+	var err error
+	if len(stable.ChildTableName) == 0 && stable.NameHash == db_table.NameHashKeyed {
+		tableName = db_table.HashTableName(stable.Name, lineData)
+	} else if tableName, err = db_table.GenerateTableName(stable.ChildTableName, lineData); err != nil {
+		log.Printf("## get table name -[%s] error %v", stable.ChildTableName, err)
 		os.Exit(1)
 	}
-	tagValues := make([]db_table.TagValue, 0, len(tags))
+	if tableName, err = namingPolicy(stable).Normalize(tableName); err != nil {
+		log.Printf("## table name of tag line %v error %v", line, err)
+		os.Exit(1)
+	}
+	tagValues := make([]db_table.TagValue, 0, len(stable.Tags))
 
-	for _, tag := range tags {
+	for _, tag := range stable.Tags {
 		tagValue, err := field.DefaultExtractor.Extract(tag.Source, lineData)
 		if err != nil {
 			log.Printf("## get tag -[%s] value error %v", tag.Field, err)
 			os.Exit(1)
 		}
+		if tagValue, err = tag.Value(tagValue); err != nil {
+			log.Printf("## get tag -[%s] value of table [%s] error %v", tag.Field, tableName, err)
+			os.Exit(1)
+		}
 		tagValues = append(tagValues, db_table.TagValue{
 			TagName:      tag.Field,
 			TagValue:     tagValue,
@@ -243,13 +733,13 @@ func tableParam(db, stable, tableNamePattern string, line map[string]string, tag
 		})
 	}
 
-	return db_table.TableParam{DBName: db, STableName: stable, TableName: tableName, TagValues: tagValues}, tableName
+	return db_table.TableParam{DBName: db.Name, STableName: stable.Name, TableName: tableName, TagValues: tagValues, Precision: db.Precision}
 }
 
-func importDataToTable(ctx context.Context, conf config.Config, ch chan string, tableNames map[string]struct{}) {
+func importDataToTable(ctx context.Context, conf config.Config, sink importer.Sink, existing *importer.Existing, ch chan string, tableNames map[string]struct{}) {
 	defer close(ch)
 	//
-	dataFiles, err := getFiles(conf.DataDir, conf.DataFiles, conf.DataFileSuffix, conf.STable.ChildTableNamePrefix, tableNames)
+	dataFiles, err := getFiles(conf.DataDir, conf.DataFiles, conf.DataFileSuffix, conf.STable, tableNames)
 	if err != nil {
 		log.Println("## get data file fail.", err)
 		os.Exit(1)
@@ -259,18 +749,18 @@ func importDataToTable(ctx context.Context, conf config.Config, ch chan string,
 
 	for i := 0; i < conf.DealOneTime; i++ {
 		wait.Add(1)
-		go doImport(ctx, conf, dataFiles, &wait, ch)
+		go doImport(ctx, conf, sink, existing, dataFiles, tableNames, &wait, ch)
 	}
 	wait.Wait()
 }
 
-func doImport(ctx context.Context, conf config.Config, files chan string, w *sync.WaitGroup, messages chan string) {
+func doImport(ctx context.Context, conf config.Config, sink importer.Sink, existing *importer.Existing, files chan string, tableNames map[string]struct{}, w *sync.WaitGroup, messages chan string) {
 	defer w.Done()
 
 	for f := range files {
 		ext := path.Ext(f)
 		if ext == ".csv" {
-			msg, err := importCsvData(ctx, conf, f)
+			msg, _, err := importCsvData(ctx, conf, sink, existing, f, tableNames)
 			if err != nil {
 				log.Printf("## import data file [%s] to tdengine fail. %v", f, err)
 			}
@@ -280,56 +770,84 @@ func doImport(ctx context.Context, conf config.Config, files chan string, w *syn
 	}
 }
 
-func importCsvData(ctx context.Context, conf config.Config, file string) (string, error) {
-	table := getTableName(file, conf.STable.ChildTableNamePrefix)
-	ci, err := importer.NewCsvImporter(conf, table)
+// importCsvData existing 不为 nil 时先按 on_existing 处理子表已有的数据，返回结果和解析或写入失败的行数。
+// explode.table 生成的子表需要在 tableNames 中
+func importCsvData(ctx context.Context, conf config.Config, sink importer.Sink, existing *importer.Existing, file string, tableNames map[string]struct{}) (string, int64, error) {
+	table, err := getTableName(file, conf.STable)
+	if err != nil {
+		return "", 0, err
+	}
+	var messages []string
+	if existing != nil {
+		if sink, messages, err = existing.Prepare(ctx, table, file, sink); err != nil {
+			return strings.Join(messages, "\n"), 0, err
+		}
+		if sink == nil {
+			messages = append(messages, fmt.Sprintf("## skip file [%s], all tables have data in its time range", file))
+			return strings.Join(messages, "\n"), 0, nil
+		}
+	}
+	ci, err := importer.NewCsvImporter(conf, table, sink)
 	if err != nil {
-		return "", err
+		return strings.Join(messages, "\n"), 0, err
 	}
+	ci.Tables = tableNames
 	err = ci.Import(ctx, file)
-	msg := fmt.Sprintf("## importe file [%s] finished. total data-[%d] error count-[%d] start-[%s] end-[%v] spend-[%d] ms",
-		file, ci.Total.Load(), ci.ErrorCount.Load(), ci.Start.Format("2006-01-02 15:04:05.000"),
+	msg := fmt.Sprintf("## importe file [%s] finished. total data-[%d] written rows-[%d] error count-[%d] filtered count-[%d] duplicate count-[%d] bars-[%d] start-[%s] end-[%v] spend-[%d] ms",
+		file, ci.Total.Load(), ci.WrittenCount.Load(), ci.ErrorCount.Load(), ci.FilteredCount.Load(), ci.DuplicateCount.Load(), ci.BarCount.Load(), ci.Start.Format("2006-01-02 15:04:05.000"),
 		ci.End.Format("2006-01-02 15:04:05.000"), ci.End.Sub(ci.Start).Milliseconds())
-	return msg, err
+	return strings.Join(append(messages, msg), "\n"), ci.ErrorCount.Load(), err
 }
 
-func getDBUri(conf config.Config) string {
-	return fmt.Sprintf("%s:%s/tcp(%s:%d)/", conf.TDEngine.User, conf.TDEngine.Password, conf.TDEngine.Host, conf.TDEngine.Port)
+// getDBUri 按 tdengine.protocol 返回 database/sql 的 driver 和 dsn
+func getDBUri(conf config.Config) (string, string) {
+	driver, network := db_table.DriverNative, "tcp"
+	switch conf.TDEngine.GetProtocol() {
+	case config.ProtocolWS:
+		driver, network = db_table.DriverWS, "ws"
+	case config.ProtocolRest:
+		driver, network = db_table.DriverRest, "http"
+	}
+	dsn := fmt.Sprintf("%s:%s@%s(%s:%d)/", conf.TDEngine.User, conf.TDEngine.Password, network, conf.TDEngine.Host, conf.TDEngine.GetPort())
+	if len(conf.TDEngine.Token) > 0 && driver != db_table.DriverNative {
+		dsn += "?token=" + url.QueryEscape(conf.TDEngine.Token)
+	}
+	return driver, dsn
 }
 
-func getTableName(file string, prefix string) string {
+func getTableName(file string, stable config.STable) (string, error) {
 	// 用 file name 做 table name
-	_, fileName := path.Split(file)
-	ext := path.Ext(file)
-	fileName = strings.TrimRight(fileName, ext)
+	return namingPolicy(stable).TableNameFromFile(file, stable.ChildTableNamePrefix)
+}
 
-	return prefix + fileName // todo
+func namingPolicy(stable config.STable) db_table.NamingPolicy {
+	return db_table.NamingPolicy{Case: stable.Naming.Case, Replace: stable.Naming.Replace, MaxLength: stable.Naming.MaxLength}
 }
 
-func getFiles(dataDir string, dataFiles []string, suffix string, tablePrefix string, tableNames map[string]struct{}) (chan string, error) {
+func getFiles(dataDir string, dataFiles []string, suffix string, stable config.STable, tableNames map[string]struct{}) (chan string, error) {
 	if len(dataDir) == 0 && len(dataFiles) == 0 {
 		log.Println("## config error, dir config and files config is null")
 		os.Exit(1)
 	}
 
 	if len(dataDir) == 0 && len(dataFiles) != 0 {
-		return getFilesFromFilesConf(dataFiles, tablePrefix, tableNames), nil
+		return getFilesFromFilesConf(dataFiles, stable, tableNames), nil
 	}
 
 	if len(dataDir) != 0 && len(dataFiles) == 0 {
-		return getFilesFromDir(dataDir, suffix, tablePrefix, tableNames)
+		return getFilesFromDir(dataDir, suffix, stable, tableNames)
 	}
 
-	return getFilesFromDirAndFiles(dataDir, dataFiles, tablePrefix, tableNames), nil
+	return getFilesFromDirAndFiles(dataDir, dataFiles, stable, tableNames), nil
 }
 
-func getFilesFromFilesConf(dataFiles []string, tablePrefix string, tableNames map[string]struct{}) chan string {
+func getFilesFromFilesConf(dataFiles []string, stable config.STable, tableNames map[string]struct{}) chan string {
 	files := make(chan string, 10)
 
 	go func() {
 		defer close(files)
 		for _, file := range dataFiles {
-			if !filterByTableName(file, tablePrefix, tableNames) {
+			if !filterByTableName(file, stable, tableNames) {
 				continue
 			}
 			files <- file
@@ -339,7 +857,7 @@ func getFilesFromFilesConf(dataFiles []string, tablePrefix string, tableNames ma
 	return files
 }
 
-func getFilesFromDir(dataDir string, suffix string, tablePrefix string, tableNames map[string]struct{}) (chan string, error) {
+func getFilesFromDir(dataDir string, suffix string, stable config.STable, tableNames map[string]struct{}) (chan string, error) {
 	files := make(chan string, 10)
 
 	go func() {
@@ -350,7 +868,7 @@ func getFilesFromDir(dataDir string, suffix string, tablePrefix string, tableNam
 			os.Exit(1)
 		}
 		for _, f := range fs {
-			if !filterByTableName(f, tablePrefix, tableNames) {
+			if !filterByTableName(f, stable, tableNames) {
 				continue
 			}
 			files <- f
@@ -381,7 +899,7 @@ func listDir(dir string, suffix string) (files []string, err error) {
 	return
 }
 
-func getFilesFromDirAndFiles(dataDir string, dataFiles []string, tablePrefix string, tableNames map[string]struct{}) chan string {
+func getFilesFromDirAndFiles(dataDir string, dataFiles []string, stable config.STable, tableNames map[string]struct{}) chan string {
 	files := make(chan string, 10)
 
 	go func() {
@@ -394,7 +912,7 @@ func getFilesFromDirAndFiles(dataDir string, dataFiles []string, tablePrefix str
 				abs = path.Join(dataDir, f)
 			}
 
-			if !filterByTableName(abs, tablePrefix, tableNames) {
+			if !filterByTableName(abs, stable, tableNames) {
 				continue
 			}
 
@@ -405,14 +923,15 @@ func getFilesFromDirAndFiles(dataDir string, dataFiles []string, tablePrefix str
 	return files
 }
 
-func filterByTableName(file string, prefix string, tables map[string]struct{}) bool {
+func filterByTableName(file string, stable config.STable, tables map[string]struct{}) bool {
 	if len(tables) == 0 {
 		return true
 	}
-	_, fileName := path.Split(file)
-	ext := path.Ext(file)
-	fileName = strings.Trim(fileName, ext)
-	fileName = prefix + fileName
-	_, exist := tables[fileName]
+	tableName, err := getTableName(file, stable)
+	if err != nil {
+		log.Printf("## skip file [%s]. %v", file, err)
+		return false
+	}
+	_, exist := tables[tableName]
 	return exist
 }
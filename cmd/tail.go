@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"taos_importer/internal/importer"
+	"time"
+)
+
+// tmqPollTimeout 一次消费等待的时间
+const tmqPollTimeout = time.Second
+
+// tailOptions mirror 不为空时把消费到的数据写入 mirror 库，否则只统计速率
+type tailOptions struct {
+	topic    string
+	group    string
+	offset   string
+	interval time.Duration
+	mirror   string
+}
+
+// poller Tail 和 Mirror
+type poller interface {
+	Poll(ctx context.Context, timeout time.Duration) (int, error)
+	Take(now time.Time) []importer.TableRate
+}
+
+// tailData 在 [stable] 的列上创建主题并订阅，每 interval 打印每个子表的速率，直到收到 SIGINT 或 SIGTERM
+func tailData(ctx context.Context, configFile string, options tailOptions) {
+	log.Println("## start to tail data. config file is ", configFile)
+	conf := readConfig(configFile)
+	if options.interval <= 0 {
+		log.Printf("## param error, interval %s", options.interval)
+		os.Exit(1)
+	}
+	if len(options.topic) == 0 {
+		options.topic = fmt.Sprintf("importer_%s_%s", conf.DB.Name, conf.STable.Name)
+	}
+	if len(options.group) == 0 {
+		options.group = "taos_importer_tail"
+		if len(options.mirror) > 0 {
+			options.group = "taos_importer_mirror_" + options.mirror
+		}
+	}
+	if len(options.offset) == 0 {
+		// 只统计时从最新的数据开始，复制时从最早的数据开始
+		options.offset = "latest"
+		if len(options.mirror) > 0 {
+			options.offset = "earliest"
+		}
+	}
+
+	dt := openDatabaseAndTable(conf)
+	defer func() { _ = dt.Close() }()
+	columns := make([]string, 0, len(conf.STable.Columns))
+	for _, column := range conf.STable.Columns {
+		columns = append(columns, column.Field)
+	}
+	err := dt.CreateTopic(ctx, db_table.TopicParam{Topic: options.topic, DBName: conf.DB.Name, STableName: conf.STable.Name, Columns: columns})
+	if err != nil {
+		log.Printf("## %v", err)
+		os.Exit(1)
+	}
+
+	consumer, err := importer.NewWSConsumer(conf, options.group, options.offset)
+	if err != nil {
+		log.Printf("## %v", err)
+		os.Exit(1)
+	}
+	defer func() { _ = consumer.Close() }()
+	if err = consumer.Subscribe([]string{options.topic}); err != nil {
+		log.Printf("## subscribe topic [%s] error %v", options.topic, err)
+		os.Exit(1)
+	}
+	log.Printf("## subscribed topic [%s] group [%s]", options.topic, options.group)
+
+	var p poller
+	var closeSink func() error
+	if len(options.mirror) > 0 {
+		var mirror *importer.Mirror
+		mirror, closeSink = openMirror(ctx, conf, options.mirror, consumer, dt)
+		p = mirror
+	} else {
+		p = importer.NewTail(consumer, time.Now())
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			reportRates(p.Take(time.Now()))
+			if closeSink != nil {
+				_ = closeSink()
+			}
+			log.Println("## tailing data finished. config file is ", configFile)
+			return
+		case now := <-ticker.C:
+			reportRates(p.Take(now))
+		default:
+		}
+		if _, err = p.Poll(ctx, tmqPollTimeout); err != nil {
+			// 出错的消息没有提交，重新运行时再次消费
+			log.Printf("## poll topic [%s] error %v", options.topic, err)
+			os.Exit(1)
+		}
+	}
+}
+
+// openMirror 在 targetDB 中创建库和 [stable] 超级表，子表在第一次写入时创建
+func openMirror(ctx context.Context, conf config.Config, targetDB string, consumer importer.Consumer, source *db_table.DatabaseAndTable) (*importer.Mirror, func() error) {
+	target := conf
+	target.DB.Name = targetDB
+	target.Aggregate = config.Aggregate{}
+	createDB(ctx, target, source)
+	createSTables(ctx, target, source)
+
+	pool, err := importer.NewPool(target)
+	if err != nil {
+		log.Printf("## connect to tdengine error %v", err)
+		os.Exit(1)
+	}
+	mirror, err := importer.NewMirror(conf, targetDB, consumer, source, source, pool, time.Now())
+	if err != nil {
+		log.Printf("## %v", err)
+		os.Exit(1)
+	}
+	log.Printf("## mirror stable [%s] from db [%s] to db [%s]", conf.STable.Name, conf.DB.Name, targetDB)
+	return mirror, func() error {
+		log.Printf("## mirror tables-[%d] rows-[%d]", mirror.Tables.Load(), mirror.Rows.Load())
+		return pool.Close()
+	}
+}
+
+func reportRates(rates []importer.TableRate) {
+	var rows int64
+	var perSecond float64
+	for _, rate := range rates {
+		log.Printf("## table [%s] rows-[%d] rate-[%.2f] rows/s", rate.Table, rate.Rows, rate.PerSecond)
+		rows += rate.Rows
+		perSecond += rate.PerSecond
+	}
+	log.Printf("## tables-[%d] rows-[%d] rate-[%.2f] rows/s", len(rates), rows, perSecond)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"taos_importer/internal/config"
+	"taos_importer/internal/importer"
+	"taos_importer/internal/verifier"
+	"time"
+)
+
+// verifyCommand verify 子命令，有不一致或查询失败的子表时退出码为 1
+func verifyCommand(ctx context.Context, configFile string, sums string) {
+	log.Println("## start to verify data. config file is ", configFile)
+	conf := readConfig(configFile)
+	loadLookups(conf)
+	results, ok := verifyData(ctx, conf, splitColumns(sums))
+	for _, msg := range results {
+		log.Println(msg)
+	}
+	if !ok {
+		os.Exit(1)
+	}
+}
+
+// verifyData 按导入的流程重新读取源文件得到每个子表的统计，再与 TDengine 中的数据核对。
+// 返回每个子表的结果和汇总，所有子表一致时返回 true
+func verifyData(ctx context.Context, conf config.Config, sums []string) ([]string, bool) {
+	start := time.Now()
+	dt := openDatabaseAndTable(conf)
+	defer func() { _ = dt.Close() }()
+
+	stats := importer.NewStats(sums)
+	tableNames := createTables(ctx, conf, dt, false)
+	ch := make(chan string, 100)
+	go importDataToTable(ctx, conf, stats, nil, ch, tableNames)
+	// 统计时不输出每个文件的结果，出错的文件已打印
+	for range ch {
+	}
+
+	v := verifier.NewVerifier(dt, conf.Concurrent)
+	results := v.Verify(ctx, stats.Tables())
+	results = append(results, fmt.Sprintf("## verify finished. tables-[%d] mismatched-[%d] failed-[%d] spend-[%d] ms",
+		v.Tables.Load(), v.Mismatched.Load(), v.Failed.Load(), time.Since(start).Milliseconds()))
+	return results, v.Mismatched.Load() == 0 && v.Failed.Load() == 0
+}
+
+// splitColumns 逗号分隔的列名
+func splitColumns(s string) []string {
+	var columns []string
+	for _, column := range strings.Split(s, ",") {
+		if column = strings.TrimSpace(column); len(column) > 0 {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
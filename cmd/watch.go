@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"taos_importer/internal/importer"
+	"taos_importer/internal/watcher"
+	"time"
+)
+
+// watchOptions 每 interval 扫描一次 data_dir，导入过的文件记录在 state，
+// doneDir、failedDir 不为空时把导入成功、失败的文件移动到这两个目录
+type watchOptions struct {
+	enabled   bool
+	interval  time.Duration
+	state     string
+	doneDir   string
+	failedDir string
+}
+
+// watchData 持续导入 data_dir 中新的文件，直到收到 SIGINT 或 SIGTERM，正在导入的一批文件完成后退出。
+// 记录导入结果失败时停止并返回 error
+func watchData(ctx context.Context, conf config.Config, creator tableCreator, sink importer.Sink, existing *importer.Existing, logfile *bufio.Writer, options watchOptions) error {
+	if len(conf.DataDir) == 0 {
+		log.Println("## config error, watch needs data_dir")
+		os.Exit(1)
+	}
+	if options.interval <= 0 {
+		log.Printf("## param error, watch interval %s", options.interval)
+		os.Exit(1)
+	}
+	if len(options.state) == 0 {
+		options.state = filepath.Join(conf.DataDir, ".taos_importer_state.json")
+	}
+	w, err := watcher.New(watcher.Options{
+		Dir:       conf.DataDir,
+		Suffix:    conf.DataFileSuffix,
+		StateFile: options.state,
+		DoneDir:   options.doneDir,
+		FailedDir: options.failedDir,
+	})
+	if err != nil {
+		log.Printf("## %v", err)
+		os.Exit(1)
+	}
+	defer func() { _ = creator.Close() }()
+	tags := newTagCache(conf)
+
+	stop, cancel := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+	ticker := time.NewTicker(options.interval)
+	defer ticker.Stop()
+	log.Printf("## watching dir [%s] every %s, state file [%s]", conf.DataDir, options.interval, options.state)
+	for {
+		ready, err := w.Poll()
+		if err != nil {
+			log.Printf("## scan dir [%s] error %v", conf.DataDir, err)
+		}
+		// 和 doImport 相同，只导入 csv 文件
+		var files []string
+		for _, f := range ready {
+			if path.Ext(f) == ".csv" {
+				files = append(files, f)
+			}
+		}
+		if len(files) > 0 {
+			results, err := importFiles(ctx, conf, sink, existing, w, tags.refresh(ctx, creator), files)
+			for _, msg := range results {
+				if len(msg) == 0 {
+					continue
+				}
+				_, _ = logfile.WriteString(msg)
+				_, _ = logfile.WriteString("\n")
+			}
+			_ = logfile.Flush()
+			if err != nil {
+				return err
+			}
+		}
+		select {
+		case <-stop.Done():
+			log.Println("## stop watching dir ", conf.DataDir)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// tagCache 记录已处理的 tag 文件，每次轮询只读取新的和变化的 tag 文件建表
+type tagCache struct {
+	conf      config.Config
+	registry  *db_table.NameRegistry
+	tables    map[string]struct{}
+	files     map[string]fileStamp // tag 文件 -> 处理时的大小和修改时间
+	conflicts map[string]struct{}  // 已打印的冲突
+}
+
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+func newTagCache(conf config.Config) *tagCache {
+	return &tagCache{
+		conf:      conf,
+		registry:  newNameRegistry(conf),
+		tables:    make(map[string]struct{}),
+		files:     make(map[string]fileStamp),
+		conflicts: make(map[string]struct{}),
+	}
+}
+
+// refresh 处理新的和变化的 tag 文件，返回所有已知的子表。变化的文件中已登记的行不会重新创建
+func (t *tagCache) refresh(ctx context.Context, creator tableCreator) map[string]struct{} {
+	var changed []string
+	stamps := make(map[string]fileStamp)
+	for _, file := range tagFiles(t.conf) {
+		info, err := os.Stat(file)
+		if err != nil {
+			log.Printf("## stat tag file [%s] error %v", file, err)
+			continue
+		}
+		stamp := fileStamp{size: info.Size(), modTime: info.ModTime()}
+		if old, ok := t.files[file]; !ok || old.size != stamp.size || !old.modTime.Equal(stamp.modTime) {
+			changed = append(changed, file)
+			stamps[file] = stamp
+		}
+	}
+	if len(changed) == 0 {
+		return t.tables
+	}
+
+	log.Printf("## create tables of new or changed tag files-%v", changed)
+	createTablesFrom(ctx, t.conf, creator, t.conf.AutoCreate, changed, t.registry, t.tables)
+	for file, stamp := range stamps {
+		t.files[file] = stamp
+	}
+	for _, msg := range t.registry.Conflicts() {
+		if _, ok := t.conflicts[msg]; !ok {
+			t.conflicts[msg] = struct{}{}
+			log.Println(msg)
+		}
+	}
+	return t.tables
+}
+
+// importFiles 导入一批写完的文件并记录结果，返回每个文件的结果。
+// 有解析或写入失败的行的文件作为失败处理，修正后移回 data_dir 再次导入；
+// 子表还不在 tag 文件中的文件不记录，等 tag 文件更新后在之后的轮询中导入。记录结果失败时返回 error
+func importFiles(ctx context.Context, conf config.Config, sink importer.Sink, existing *importer.Existing, w *watcher.Watcher, tableNames map[string]struct{}, files []string) ([]string, error) {
+	start := time.Now()
+
+	ch := make(chan int)
+	results := make([]string, len(files))
+	var failed, waiting atomic.Int64
+	var doneErr atomic.Value
+	var wait sync.WaitGroup
+	go func() {
+		defer close(ch)
+		for i := range files {
+			ch <- i
+		}
+	}()
+	workers := conf.DealOneTime
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range ch {
+				f := files[i]
+				if doneErr.Load() != nil {
+					continue
+				}
+				if !filterByTableName(f, conf.STable, tableNames) {
+					log.Printf("## table of data file [%s] is not in tag files, retry later", f)
+					waiting.Add(1)
+					continue
+				}
+				var messages []string
+				msg, errorRows, err := importCsvData(ctx, conf, sink, existing, f, tableNames)
+				if len(msg) > 0 {
+					messages = append(messages, msg)
+				}
+				if err == nil && errorRows > 0 {
+					err = fmt.Errorf("%d rows are not written", errorRows)
+				}
+				if err != nil {
+					log.Printf("## import data file [%s] to tdengine fail. %v", f, err)
+					messages = append(messages, fmt.Sprintf("## import data file [%s] error %v", f, err))
+					failed.Add(1)
+				}
+				moved, err := w.Done(f, err)
+				if err != nil {
+					doneErr.CompareAndSwap(nil, fmt.Errorf("record file [%s] error %v", f, err))
+					results[i] = strings.Join(messages, "\n")
+					continue
+				}
+				if len(moved) > 0 {
+					messages = append(messages, fmt.Sprintf("## move file [%s] to [%s]", f, moved))
+				}
+				results[i] = strings.Join(messages, "\n")
+			}
+		}()
+	}
+	wait.Wait()
+	log.Printf("## imported new files-[%d] failed-[%d] waiting for tags-[%d] spend-[%d] ms", int64(len(files))-waiting.Load(), failed.Load(), waiting.Load(), time.Since(start).Milliseconds())
+	if err, ok := doneErr.Load().(error); ok {
+		return results, err
+	}
+	return results, nil
+}
@@ -3,12 +3,14 @@ module taos_importer
 go 1.19
 
 require (
-	github.com/allegro/bigcache/v3 v3.1.0
+	github.com/gorilla/websocket v1.5.0
 	github.com/pelletier/go-toml/v2 v2.0.6
 	github.com/taosdata/driver-go/v3 v3.0.4
 )
 
 require (
 	github.com/google/uuid v1.3.0 // indirect
-	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/json-iterator/go v1.1.12 // indirect
+	github.com/modern-go/concurrent v0.0.0-20180228061459-e0a39a4cb421 // indirect
+	github.com/modern-go/reflect2 v1.0.2 // indirect
 )
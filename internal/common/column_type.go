@@ -17,4 +17,6 @@ const (
 	TypeNchar            = "nchar"
 	TypeJson             = "json"
 	TypeVarchar          = "varchar"
+	TypeVarbinary        = "varbinary"
+	TypeGeometry         = "geometry"
 )
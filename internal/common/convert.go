@@ -0,0 +1,218 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// BaseType 返回去掉长度和多余空格的小写类型名，如 "VARCHAR(10)" -> "varchar"，"bigint  unsigned" -> "bigint unsigned"
+func BaseType(columnType string) string {
+	t, _, _ := strings.Cut(columnType, "(")
+	return strings.Join(strings.Fields(strings.ToLower(t)), " ")
+}
+
+// intBits 有符号和无符号整数类型的位数
+var intBits = map[string]int{
+	TypeTinyInt:          8,
+	TypeTinyIntUnsigned:  8,
+	TypeSmallInt:         16,
+	TypeSmallIntUnSigned: 16,
+	TypeInt:              32,
+	TypeIntUnSigned:      32,
+	TypeBigInt:           64,
+	TypeBigIntUnsigned:   64,
+}
+
+// IsUnsigned 是否为无符号整数类型
+func IsUnsigned(columnType string) bool {
+	return strings.HasSuffix(BaseType(columnType), " unsigned")
+}
+
+// Convert 把 value 转换为 TDengine 类型 columnType 对应的 Go 类型，整数会检查范围：
+//
+//	tinyint/smallint/int/bigint -> int64, 无符号整数 -> uint64, float -> float32, double -> float64, bool -> bool,
+//	timestamp -> time.Time, binary/varchar/varbinary/geometry -> []byte, nchar/json -> string
+//
+// precision 只用于整数时间戳。value 为 nil 时返回 nil
+func Convert(value any, columnType string, precision string) (any, error) {
+	if value == nil {
+		return nil, nil
+	}
+
+	t := BaseType(columnType)
+	if bits, ok := intBits[t]; ok {
+		if IsUnsigned(t) {
+			return ParseUint(value, bits)
+		}
+		return ParseInt(value, bits)
+	}
+
+	switch t {
+	case TypeTimeStamp:
+		return Timestamp(value, precision)
+	case TypeFloat:
+		v, err := Float64(value)
+		if err != nil {
+			return nil, err
+		}
+		if !math.IsInf(v, 0) && !math.IsNaN(v) && math.Abs(v) > math.MaxFloat32 {
+			return nil, fmt.Errorf("value [%v] out of range of %s", value, t)
+		}
+		return float32(v), nil
+	case TypeDouble:
+		return Float64(value)
+	case TypeBool:
+		return Bool(value)
+	case TypeBinary, TypeVarchar, TypeGeometry:
+		return []byte(String(value)), nil
+	case TypeVarbinary:
+		return Varbinary(value)
+	case TypeNchar, TypeJson:
+		return String(value), nil
+	default:
+		return nil, fmt.Errorf("unknown column type [%s]", columnType)
+	}
+}
+
+// ParseInt 转换为 bits 位有符号整数，超出范围时返回 error。浮点数必须是整数值
+func ParseInt(a any, bits int) (int64, error) {
+	min, max := int64(-1)<<(bits-1), int64(1)<<(bits-1)-1
+	var v int64
+
+	switch a := a.(type) {
+	case int:
+		v = int64(a)
+	case int8:
+		v = int64(a)
+	case int16:
+		v = int64(a)
+	case int32:
+		v = int64(a)
+	case int64:
+		v = a
+	case uint, uint8, uint16, uint32, uint64:
+		u, err := ParseUint(a, 64)
+		if err != nil {
+			return 0, err
+		}
+		if u > uint64(max) {
+			return 0, fmt.Errorf("value [%d] out of range [%d, %d]", u, min, max)
+		}
+		v = int64(u)
+	case float32:
+		return ParseInt(float64(a), bits)
+	case float64:
+		if a != math.Trunc(a) || a < float64(min) || a >= -float64(min) {
+			return 0, fmt.Errorf("value [%v] is not an integer in range [%d, %d]", a, min, max)
+		}
+		v = int64(a)
+	case bool:
+		if a {
+			v = 1
+		}
+	case string:
+		s := strings.TrimSpace(a)
+		i, err := strconv.ParseInt(s, 10, 64)
+		if errors.Is(err, strconv.ErrSyntax) {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil {
+				return 0, fmt.Errorf("value [%s] is not an integer", a)
+			}
+			return ParseInt(f, bits)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("value [%s] out of range [%d, %d]", a, min, max)
+		}
+		v = i
+	default:
+		return 0, fmt.Errorf("unknown type %T ", a)
+	}
+
+	if v < min || v > max {
+		return 0, fmt.Errorf("value [%d] out of range [%d, %d]", v, min, max)
+	}
+	return v, nil
+}
+
+// ParseUint 转换为 bits 位无符号整数，负数和超出范围时返回 error。浮点数必须是整数值
+func ParseUint(a any, bits int) (uint64, error) {
+	max := uint64(math.MaxUint64) >> (64 - bits)
+	var v uint64
+
+	switch a := a.(type) {
+	case uint:
+		v = uint64(a)
+	case uint8:
+		v = uint64(a)
+	case uint16:
+		v = uint64(a)
+	case uint32:
+		v = uint64(a)
+	case uint64:
+		v = a
+	case int, int8, int16, int32, int64:
+		i, err := ParseInt(a, 64)
+		if err != nil {
+			return 0, err
+		}
+		if i < 0 {
+			return 0, fmt.Errorf("value [%d] out of range [0, %d]", i, max)
+		}
+		v = uint64(i)
+	case float32:
+		return ParseUint(float64(a), bits)
+	case float64:
+		if a != math.Trunc(a) || a < 0 || a >= float64(max)+1 {
+			return 0, fmt.Errorf("value [%v] is not an integer in range [0, %d]", a, max)
+		}
+		v = uint64(a)
+	case bool:
+		if a {
+			v = 1
+		}
+	case string:
+		s := strings.TrimSpace(a)
+		u, err := strconv.ParseUint(strings.TrimPrefix(s, "+"), 10, 64)
+		if errors.Is(err, strconv.ErrSyntax) {
+			f, ferr := strconv.ParseFloat(s, 64)
+			if ferr != nil {
+				return 0, fmt.Errorf("value [%s] is not an integer", a)
+			}
+			return ParseUint(f, bits)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("value [%s] out of range [0, %d]", a, max)
+		}
+		v = u
+	default:
+		return 0, fmt.Errorf("unknown type %T ", a)
+	}
+
+	if v > max {
+		return 0, fmt.Errorf("value [%d] out of range [0, %d]", v, max)
+	}
+	return v, nil
+}
+
+// Varbinary 以 \x 或 0x 开头的字符串按十六进制解码，其他按原始字节
+func Varbinary(a any) ([]byte, error) {
+	switch a := a.(type) {
+	case []byte:
+		return a, nil
+	case string:
+		if strings.HasPrefix(a, `\x`) || strings.HasPrefix(a, "0x") || strings.HasPrefix(a, "0X") {
+			b, err := hex.DecodeString(a[2:])
+			if err != nil {
+				return nil, fmt.Errorf("value [%s] is not a hex string", a)
+			}
+			return b, nil
+		}
+		return []byte(a), nil
+	default:
+		return []byte(String(a)), nil
+	}
+}
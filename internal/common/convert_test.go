@@ -0,0 +1,102 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestConvert(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      any
+		columnType string
+		expect     any
+	}{
+		{name: "tinyint", value: "-128", columnType: "tinyint", expect: int64(-128)},
+		{name: "smallint", value: 32767, columnType: "smallint", expect: int64(32767)},
+		{name: "int float", value: "100.0", columnType: "int", expect: int64(100)},
+		{name: "bigint unsigned", value: "18446744073709551615", columnType: "bigint unsigned", expect: uint64(18446744073709551615)},
+		{name: "tinyint unsigned", value: " 255 ", columnType: "TINYINT  UNSIGNED", expect: uint64(255)},
+		{name: "float", value: "1.5", columnType: "float", expect: float32(1.5)},
+		{name: "bool", value: "true", columnType: "bool", expect: true},
+		{name: "timestamp", value: "1669167985100", columnType: "timestamp", expect: time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC)},
+		{name: "nchar", value: 12, columnType: "nchar(10)", expect: "12"},
+		{name: "nil", value: nil, columnType: "int", expect: nil},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := Convert(c.value, c.columnType, "ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts, ok := c.expect.(time.Time); ok {
+				if !ts.Equal(res.(time.Time)) {
+					t.Fatalf("expect-[%v] but got-[%v]", c.expect, res)
+				}
+				return
+			}
+			if res != c.expect {
+				t.Fatalf("expect-[%v](%T) but got-[%v](%T)", c.expect, c.expect, res, res)
+			}
+		})
+	}
+}
+
+func TestConvert_Bytes(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      any
+		columnType string
+		expect     []byte
+	}{
+		{name: "varchar", value: "abc", columnType: "varchar(10)", expect: []byte("abc")},
+		{name: "varbinary hex", value: `\x0aff`, columnType: "varbinary(10)", expect: []byte{0x0a, 0xff}},
+		{name: "varbinary 0x", value: "0x41", columnType: "varbinary(10)", expect: []byte("A")},
+		{name: "varbinary raw", value: "abc", columnType: "varbinary(10)", expect: []byte("abc")},
+		{name: "geometry", value: "POINT(1 2)", columnType: "geometry(64)", expect: []byte("POINT(1 2)")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := Convert(c.value, c.columnType, "ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(res.([]byte), c.expect) {
+				t.Fatalf("expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestConvert_Error(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      any
+		columnType string
+	}{
+		{name: "tinyint overflow", value: "128", columnType: "tinyint"},
+		{name: "smallint overflow", value: int64(-32769), columnType: "smallint"},
+		{name: "int overflow", value: 2147483648.0, columnType: "int"},
+		{name: "bigint overflow", value: "9223372036854775808", columnType: "bigint"},
+		{name: "unsigned negative", value: "-1", columnType: "int unsigned"},
+		{name: "unsigned negative int", value: -1, columnType: "bigint unsigned"},
+		{name: "unsigned overflow", value: "256", columnType: "tinyint unsigned"},
+		{name: "bigint unsigned overflow", value: "18446744073709551616", columnType: "bigint unsigned"},
+		{name: "not integer", value: "1.5", columnType: "int"},
+		{name: "not number", value: "abc", columnType: "bigint"},
+		{name: "float overflow", value: "1e39", columnType: "float"},
+		{name: "bad hex", value: `\xzz`, columnType: "varbinary(10)"},
+		{name: "unknown type", value: "1", columnType: "decimal(10,2)"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if res, err := Convert(c.value, c.columnType, "ms"); err == nil {
+				t.Fatalf("expect error but got-[%v]", res)
+			}
+		})
+	}
+}
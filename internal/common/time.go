@@ -0,0 +1,178 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	PrecisionMilliSecond = "ms"
+	PrecisionMicroSecond = "us"
+	PrecisionNanoSecond  = "ns"
+)
+
+var iso8601Layouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04:05Z0700",
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"20060102T150405Z0700",
+	"20060102T150405",
+	compactDateLayout,
+}
+
+// compactDateLayout 纯数字的日期，Timestamp 中优先于 unix 时间
+const compactDateLayout = "20060102"
+
+var locations sync.Map
+
+// Location 同 time.LoadLocation，但缓存加载结果
+func Location(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// FromUnix 把 unit(s|ms|us|ns) 精度的 unix 时间转为 time.Time
+func FromUnix(v int64, unit string) (time.Time, error) {
+	switch unit {
+	case "s":
+		return time.Unix(v, 0), nil
+	case PrecisionMilliSecond:
+		return time.UnixMilli(v), nil
+	case PrecisionMicroSecond:
+		return time.UnixMicro(v), nil
+	case PrecisionNanoSecond:
+		return time.Unix(0, v), nil
+	default:
+		return time.Time{}, fmt.Errorf("unknown unix time unit [%s]", unit)
+	}
+}
+
+// ToUnix 把 t 转为 unit(s|ms|us|ns) 精度的 unix 时间
+func ToUnix(t time.Time, unit string) (int64, error) {
+	switch unit {
+	case "s":
+		return t.Unix(), nil
+	case PrecisionMilliSecond:
+		return t.UnixMilli(), nil
+	case PrecisionMicroSecond:
+		return t.UnixMicro(), nil
+	case PrecisionNanoSecond:
+		return t.UnixNano(), nil
+	default:
+		return 0, fmt.Errorf("unknown unix time unit [%s]", unit)
+	}
+}
+
+// FromExcel 把 excel 序列日期（1899-12-30 起的天数，小数部分为一天内的时间）转为 time.Time
+func FromExcel(serial float64, location *time.Location) time.Time {
+	days, frac := math.Modf(serial)
+	base := time.Date(1899, 12, 30, 0, 0, 0, 0, location)
+	return base.AddDate(0, 0, int(days)).Add(time.Duration(math.Round(frac * float64(24*time.Hour))))
+}
+
+// ParseISO8601 解析 ISO-8601 格式的时间，未带时区的按 UTC 处理
+func ParseISO8601(s string) (t time.Time, err error) {
+	return ParseISO8601In(s, time.UTC)
+}
+
+// ParseISO8601In 同 ParseISO8601，没有时区的时间按 location 解析
+func ParseISO8601In(s string, location *time.Location) (t time.Time, err error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range iso8601Layouts {
+		if t, err = time.ParseInLocation(layout, s, location); err == nil {
+			return t, nil
+		}
+	}
+	return t, fmt.Errorf("parse [%s] as iso8601 fail", s)
+}
+
+// ParseDuration 同 time.ParseDuration，另外支持 d(天) 和 w(周)
+func ParseDuration(s string) (time.Duration, error) {
+	unit := time.Duration(0)
+	switch {
+	case strings.HasSuffix(s, "d"):
+		unit = 24 * time.Hour
+	case strings.HasSuffix(s, "w"):
+		unit = 7 * 24 * time.Hour
+	default:
+		return time.ParseDuration(s)
+	}
+
+	n, err := strconv.ParseFloat(s[:len(s)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("unknown duration [%s]", s)
+	}
+	return time.Duration(n * float64(unit)), nil
+}
+
+// TruncateTime 同 time.Truncate，但整天的周期按 t 所在时区的零点对齐
+func TruncateTime(t time.Time, d time.Duration) time.Time {
+	day := 24 * time.Hour
+	if d <= 0 || d%day != 0 {
+		return t.Truncate(d)
+	}
+
+	y, m, dd := t.Date()
+	start := time.Date(y, m, dd, 0, 0, 0, 0, t.Location())
+	if d == day {
+		return start
+	}
+
+	epoch := time.Date(1970, 1, 1, 0, 0, 0, 0, t.Location())
+	days := int(start.Sub(epoch).Round(day) / day)
+	n := int(d / day)
+	if days%n < 0 {
+		days -= n + days%n
+	} else {
+		days -= days % n
+	}
+	return epoch.AddDate(0, 0, days)
+}
+
+// Timestamp 把 a 转为时间。整数（或纯数字的字符串）按 precision(ms|us|ns) 精度的 unix 时间处理，其他字符串按 ISO-8601 解析。
+// 8 位数字的字符串先按 20060102 解析，不是合法日期时才按 unix 时间处理
+func Timestamp(a any, precision string) (time.Time, error) {
+	if len(precision) == 0 {
+		precision = PrecisionMilliSecond
+	}
+
+	switch a := a.(type) {
+	case time.Time:
+		return a, nil
+	case int, int32, int64, float32, float64:
+		v, err := Int64(a)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return FromUnix(v, precision)
+	case string:
+		if len(a) == len(compactDateLayout) {
+			if t, err := time.Parse(compactDateLayout, a); err == nil {
+				return t, nil
+			}
+		}
+		if v, err := strconv.ParseInt(a, 10, 64); err == nil {
+			return FromUnix(v, precision)
+		}
+		return ParseISO8601(a)
+	default:
+		return Time(a)
+	}
+}
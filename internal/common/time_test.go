@@ -0,0 +1,143 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimestamp(t *testing.T) {
+	cases := []struct {
+		name      string
+		value     any
+		precision string
+		expect    time.Time
+	}{
+		{
+			name:      "1",
+			value:     int64(1669167985100),
+			precision: "ms",
+			expect:    time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC),
+		},
+		{
+			name:      "2",
+			value:     "1669167985100000",
+			precision: "us",
+			expect:    time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC),
+		},
+		{
+			name:      "3",
+			value:     "2022-11-23T01:46:25.1Z",
+			precision: "ns",
+			expect:    time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC),
+		},
+		{
+			name:      "4",
+			value:     1669167985100,
+			precision: "",
+			expect:    time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC),
+		},
+		{
+			name:      "compact date before unix",
+			value:     "20221123",
+			precision: "ms",
+			expect:    time.Date(2022, 11, 23, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:      "8 digits not a date",
+			value:     "20221399",
+			precision: "ms",
+			expect:    time.UnixMilli(20221399),
+		},
+		{
+			name:      "integer 8 digits",
+			value:     int64(20221123),
+			precision: "ms",
+			expect:    time.UnixMilli(20221123),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ts, err := Timestamp(c.value, c.precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !ts.Equal(c.expect) {
+				t.Fatalf("expect-[%v], but got-[%v]", c.expect, ts)
+			}
+		})
+	}
+}
+
+func TestTruncateTime(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		ts     time.Time
+		d      string
+		expect time.Time
+	}{
+		{
+			name:   "5m",
+			ts:     time.Date(2022, 11, 23, 9, 46, 25, 0, shanghai),
+			d:      "5m",
+			expect: time.Date(2022, 11, 23, 9, 45, 0, 0, shanghai),
+		},
+		{
+			name:   "1d",
+			ts:     time.Date(2022, 11, 23, 1, 46, 25, 0, shanghai),
+			d:      "1d",
+			expect: time.Date(2022, 11, 23, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:   "2d",
+			ts:     time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC),
+			d:      "2d",
+			expect: time.Date(2022, 11, 22, 0, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, err := ParseDuration(c.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res := TruncateTime(c.ts, d); !res.Equal(c.expect) {
+				t.Fatalf("expect-[%v], but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestToUnix(t *testing.T) {
+	ts := time.Date(2022, 11, 23, 1, 46, 25, 100200300, time.UTC)
+	cases := []struct {
+		name   string
+		unit   string
+		expect int64
+		err    bool
+	}{
+		{name: "s", unit: "s", expect: 1669167985},
+		{name: "ms", unit: "ms", expect: 1669167985100},
+		{name: "us", unit: "us", expect: 1669167985100200},
+		{name: "ns", unit: "ns", expect: 1669167985100200300},
+		{name: "unknown", unit: "m", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			v, err := ToUnix(ts, c.unit)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if v != c.expect {
+				t.Fatalf("expect-[%d], but got-[%d]", c.expect, v)
+			}
+		})
+	}
+}
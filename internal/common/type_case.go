@@ -89,6 +89,8 @@ func Float64(a any) (float64, error) {
 		return float64(a), nil
 	case int64:
 		return float64(a), nil
+	case uint64:
+		return float64(a), nil
 	case float32:
 		return float64(a), nil
 	case float64:
@@ -1,29 +1,71 @@
 package config
 
+import "fmt"
+
 type Config struct {
-	Title          string   `json:"title,omitempty" yaml:"title" toml:"title"`
-	AutoCreate     bool     `json:"auto_create,omitempty" yaml:"auto_create" toml:"auto_create"`
-	OutputFile     string   `json:"output_file,omitempty" yaml:"output_file" toml:"output_file"`
-	DataDir        string   `json:"data_dir,omitempty" yaml:"data_dir" toml:"data_dir"`
-	DataFileSuffix string   `json:"data_file_suffix,omitempty" yaml:"data_file_suffix" toml:"data_file_suffix"`
-	DataFiles      []string `json:"data_files,omitempty" yaml:"data_files" toml:"data_files"`
-	TagsDir        string   `json:"tags_dir,omitempty" yaml:"tags_dir" toml:"tags_dir"`
-	TagsFileSuffix string   `json:"tags_file_suffix,omitempty" yaml:"tags_file_suffix" toml:"tags_file_suffix"`
-	TagsFiles      []string `json:"tags_files,omitempty" yaml:"tags_files" toml:"tags_files"`
-	BatchSize      int      `json:"batch_size,omitempty" yaml:"batch_size" toml:"batch_size"`
-	DealOneTime    int      `json:"deal_one_time" yaml:"deal_one_time" toml:"deal_one_time"`
-	Concurrent     int      `json:"concurrent" yaml:"concurrent" toml:"concurrent"`
-	Pprof          bool     `json:"pprof" yaml:"pprof" toml:"pprof"`
-	TDEngine       TDEngine `json:"tdengine" yaml:"tdengine" toml:"tdengine"`
-	DB             Database `json:"db" yaml:"db" toml:"db"`
-	STable         STable   `json:"stable" yaml:"stable" toml:"stable"`
+	Title          string    `json:"title,omitempty" yaml:"title" toml:"title"`
+	AutoCreate     bool      `json:"auto_create,omitempty" yaml:"auto_create" toml:"auto_create"`
+	OutputFile     string    `json:"output_file,omitempty" yaml:"output_file" toml:"output_file"`
+	DataDir        string    `json:"data_dir,omitempty" yaml:"data_dir" toml:"data_dir"`
+	DataFileSuffix string    `json:"data_file_suffix,omitempty" yaml:"data_file_suffix" toml:"data_file_suffix"`
+	DataFiles      []string  `json:"data_files,omitempty" yaml:"data_files" toml:"data_files"`
+	TagsDir        string    `json:"tags_dir,omitempty" yaml:"tags_dir" toml:"tags_dir"`
+	TagsFileSuffix string    `json:"tags_file_suffix,omitempty" yaml:"tags_file_suffix" toml:"tags_file_suffix"`
+	TagsFiles      []string  `json:"tags_files,omitempty" yaml:"tags_files" toml:"tags_files"`
+	BatchSize      int       `json:"batch_size,omitempty" yaml:"batch_size" toml:"batch_size"`
+	CreateBatch    int       `json:"create_batch,omitempty" yaml:"create_batch" toml:"create_batch"` // 一条语句创建的子表数, 默认 500
+	DealOneTime    int       `json:"deal_one_time" yaml:"deal_one_time" toml:"deal_one_time"`
+	Concurrent     int       `json:"concurrent" yaml:"concurrent" toml:"concurrent"`
+	Pprof          bool      `json:"pprof" yaml:"pprof" toml:"pprof"`
+	TDEngine       TDEngine  `json:"tdengine" yaml:"tdengine" toml:"tdengine"`
+	DB             Database  `json:"db" yaml:"db" toml:"db"`
+	STable         STable    `json:"stable" yaml:"stable" toml:"stable"`
+	Lookups        []Lookup  `json:"lookups,omitempty" yaml:"lookups" toml:"lookups"`
+	Aggregate      Aggregate `json:"aggregate,omitempty" yaml:"aggregate" toml:"aggregate"`
 }
 
+const (
+	ProtocolNative = "native" // cgo 驱动, 需要安装 TDengine 客户端
+	ProtocolWS     = "ws"     // 通过 taosAdapter 的 websocket 接口
+	ProtocolRest   = "rest"   // 通过 taosAdapter 的 REST 接口
+)
+
 type TDEngine struct {
-	Host     string `json:"host,omitempty" yaml:"host" toml:"host"`
-	Port     int    `json:"port,omitempty" yaml:"port" toml:"port"`
-	User     string `json:"user,omitempty" yaml:"user" toml:"user"`
-	Password string `json:"password,omitempty" yaml:"password" toml:"password"`
+	Protocol     string `json:"protocol,omitempty" yaml:"protocol" toml:"protocol"` // native | ws | rest, 默认 native
+	Host         string `json:"host,omitempty" yaml:"host" toml:"host"`
+	Port         int    `json:"port,omitempty" yaml:"port" toml:"port"`
+	User         string `json:"user,omitempty" yaml:"user" toml:"user"`
+	Password     string `json:"password,omitempty" yaml:"password" toml:"password"`
+	Token        string `json:"token,omitempty" yaml:"token" toml:"token"`                            // ws 和 rest 的 token, 配置后不使用 user 和 password
+	MaxSqlLength int    `json:"max_sql_length,omitempty" yaml:"max_sql_length" toml:"max_sql_length"` // rest 写入和批量建表时一条 SQL 的最大长度, 默认 1048576
+	Timezone     string `json:"timezone,omitempty" yaml:"timezone" toml:"timezone"`                   // 客户端(ws 和 rest 为 taosAdapter)的时区, 查询到的时间戳 tag 按这个时区解析, 默认本地时区
+}
+
+// GetProtocol 返回连接方式, 未配置时为 native
+func (t TDEngine) GetProtocol() string {
+	if len(t.Protocol) == 0 {
+		return ProtocolNative
+	}
+	return t.Protocol
+}
+
+// GetMaxSqlLength rest 写入时一条 SQL 的最大长度, 默认 1048576
+func (t TDEngine) GetMaxSqlLength() int {
+	if t.MaxSqlLength > 0 {
+		return t.MaxSqlLength
+	}
+	return 1048576
+}
+
+// GetPort 未配置端口时 native 使用 6030, ws 和 rest 使用 taosAdapter 的 6041
+func (t TDEngine) GetPort() int {
+	if t.Port > 0 {
+		return t.Port
+	}
+	if t.GetProtocol() == ProtocolNative {
+		return 6030
+	}
+	return 6041
 }
 
 type Database struct {
@@ -41,12 +83,107 @@ type STable struct {
 	Name                 string   `json:"name,omitempty" yaml:"name" toml:"name"`
 	ChildTableNamePrefix string   `json:"child_table_name_prefix" yaml:"child_table_name_prefix" toml:"child_table_name_prefix"`
 	ChildTableName       string   `json:"child_table_name,omitempty" yaml:"child_table_name" toml:"child_table_name"`
+	NameHash             string   `json:"name_hash,omitempty" yaml:"name_hash" toml:"name_hash"`                // 未配置 child_table_name 时的 hash 方式, 为空按值计算, keyed 按超级表名和 key=value 计算
+	NameCollision        string   `json:"name_collision,omitempty" yaml:"name_collision" toml:"name_collision"` // 子表名冲突的处理: first_wins | error | suffix, 默认 first_wins
+	Naming               Naming   `json:"naming,omitempty" yaml:"naming" toml:"naming"`
 	Columns              []Column `json:"columns,omitempty" yaml:"columns" toml:"columns"`
 	Tags                 []Column `json:"tags,omitempty" yaml:"tags" toml:"tags"`
+	Dedup                Dedup    `json:"dedup,omitempty" yaml:"dedup" toml:"dedup"`
+	Filter               string   `json:"filter,omitempty" yaml:"filter" toml:"filter"`                // 过滤表达式，结果为 false 的行不导入
+	From                 string   `json:"from,omitempty" yaml:"from" toml:"from"`                      // 只导入时间戳 >= from 的行
+	To                   string   `json:"to,omitempty" yaml:"to" toml:"to"`                            // 只导入时间戳 < to 的行
+	OnExisting           string   `json:"on_existing,omitempty" yaml:"on_existing" toml:"on_existing"` // 子表已有数据时的处理: append | skip_table | replace_range | replace_table, 默认 append
+	Explode              Explode  `json:"explode,omitempty" yaml:"explode" toml:"explode"`
+	Sort                 Sort     `json:"sort,omitempty" yaml:"sort" toml:"sort"`
+}
+
+// Explode 把一行源数据展开为多行，ranges 和 expression 二选一
+type Explode struct {
+	Index      string   `json:"index,omitempty" yaml:"index" toml:"index"`                // 下标变量名, 默认 i
+	Ranges     []string `json:"ranges,omitempty" yaml:"ranges" toml:"ranges"`             // 下标范围, 如 ["1-10"]
+	Expression string   `json:"expression,omitempty" yaml:"expression" toml:"expression"` // 结果为数组的表达式, 如 seq(1, 10)
+	Table      string   `json:"table,omitempty" yaml:"table" toml:"table"`                // 子表名表达式, 为空则写入文件对应的子表
+}
+
+// Naming 子表名的规范化规则
+type Naming struct {
+	Case      string `json:"case,omitempty" yaml:"case" toml:"case"`                   // lower | upper | keep, 默认 lower
+	Replace   string `json:"replace,omitempty" yaml:"replace" toml:"replace"`          // 字母、数字和下划线以外字符的替换字符串, 默认 _
+	MaxLength int    `json:"max_length,omitempty" yaml:"max_length" toml:"max_length"` // 默认 192, 超出时截断并加上 hash
+}
+
+// Dedup 同一子表内时间戳冲突的处理方式
+type Dedup struct {
+	Strategy string `json:"strategy,omitempty" yaml:"strategy" toml:"strategy"` // bump | drop | keep_last | report, 为空则不处理
+	Window   string `json:"window,omitempty" yaml:"window" toml:"window"`       // 记录已用时间戳的时间窗口, 默认 10m
+}
+
+// Sort 写入前按时间戳对同一子表的行排序
+type Sort struct {
+	Scope string `json:"scope,omitempty" yaml:"scope" toml:"scope"` // file | window, 为空则不排序
+	Size  int    `json:"size,omitempty" yaml:"size" toml:"size"`    // window 时每个子表最多缓存的行数, 默认 10000
 }
 
 type Column struct {
+	Field      string   `json:"field,omitempty" yaml:"field" toml:"field"`
+	Type       string   `json:"type,omitempty" yaml:"type" toml:"type"`
+	Source     string   `json:"source,omitempty" yaml:"source" toml:"source"`
+	NullValues []string `json:"null_values,omitempty" yaml:"null_values" toml:"null_values"` // 视为 null 的值, 未配置时为 [""]
+	Default    any      `json:"default,omitempty" yaml:"default" toml:"default"`             // 值为 null 时使用的默认值
+	NotNull    bool     `json:"not_null,omitempty" yaml:"not_null" toml:"not_null"`          // 为 true 时值（取默认值后）为 null 报错
+}
+
+// Value 按 null_values、default 和 not_null 处理 source 表达式的结果
+func (c Column) Value(value any) (any, error) {
+	if c.IsNull(value) {
+		value = c.Default
+	}
+	if value == nil && c.NotNull {
+		return nil, fmt.Errorf("column-[%s] is null but not_null is set", c.Field)
+	}
+	return value, nil
+}
+
+// IsNull value 为 nil 或者是 null_values 中的字符串
+func (c Column) IsNull(value any) bool {
+	s, ok := value.(string)
+	if !ok {
+		return value == nil
+	}
+	if c.NullValues == nil {
+		return len(s) == 0
+	}
+	for _, v := range c.NullValues {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup 字典，启动时加载到内存，供表达式中的 lookup(name, key, default) 使用
+type Lookup struct {
+	Name      string `json:"name,omitempty" yaml:"name" toml:"name"`
+	File      string `json:"file,omitempty" yaml:"file" toml:"file"`
+	Format    string `json:"format,omitempty" yaml:"format" toml:"format"`          // csv | kv, 默认按文件后缀判断
+	Key       string `json:"key,omitempty" yaml:"key" toml:"key"`                   // csv 的 key 列
+	Value     string `json:"value,omitempty" yaml:"value" toml:"value"`             // csv 的 value 列
+	Separator string `json:"separator,omitempty" yaml:"separator" toml:"separator"` // kv 的分隔符, 默认 =
+}
+
+// Aggregate 按子表和时间窗口聚合（比如由 tick 生成 K 线），写入另一个超级表
+type Aggregate struct {
+	STable      string            `json:"stable,omitempty" yaml:"stable" toml:"stable"`
+	Window      string            `json:"window,omitempty" yaml:"window" toml:"window"`                   // 1m, 5m, 1d
+	Mode        string            `json:"mode,omitempty" yaml:"mode" toml:"mode"`                         // alongside | instead, 默认 alongside
+	TableSuffix string            `json:"table_suffix,omitempty" yaml:"table_suffix" toml:"table_suffix"` // 默认 _<window>
+	Buffer      int               `json:"buffer,omitempty" yaml:"buffer" toml:"buffer"`                   // 每个子表排序缓存的行数, 默认 10000
+	Columns     []AggregateColumn `json:"columns,omitempty" yaml:"columns" toml:"columns"`
+}
+
+type AggregateColumn struct {
 	Field  string `json:"field,omitempty" yaml:"field" toml:"field"`
 	Type   string `json:"type,omitempty" yaml:"type" toml:"type"`
-	Source string `json:"source,omitempty" yaml:"source" toml:"source"`
+	Func   string `json:"func,omitempty" yaml:"func" toml:"func"`       // first | last | min | max | sum | count | avg
+	Source string `json:"source,omitempty" yaml:"source" toml:"source"` // 原始超级表的列名
 }
@@ -0,0 +1,263 @@
+package db_table
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"taos_importer/internal/common"
+	"time"
+)
+
+// ExistingTables 从 information_schema 查询超级表已有的子表及其 tag 值，tag 值为 null 时不在 map 中。
+// 时间戳 tag 按连接的时区解析后转为带时区的 RFC3339 字符串
+func (m *DatabaseAndTable) ExistingTables(ctx context.Context, db string, stable string) (map[string]map[string]string, error) {
+	tables := make(map[string]map[string]string)
+	where := fmt.Sprintf("where db_name = %s and stable_name = %s", Quote(db), Quote(stable))
+
+	rows, err := m.conn.QueryContext(ctx, "select table_name from information_schema.ins_tables "+where)
+	if err != nil {
+		return nil, fmt.Errorf("query tables of stable [%s] error %v", stable, err)
+	}
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		tables[table] = make(map[string]string)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.conn.QueryContext(ctx, "select table_name, tag_name, tag_type, tag_value from information_schema.ins_tags "+where)
+	if err != nil {
+		return nil, fmt.Errorf("query tags of stable [%s] error %v", stable, err)
+	}
+	defer func() { _ = rows.Close() }()
+	for rows.Next() {
+		var table, tag, tagType string
+		var value *string
+		if err = rows.Scan(&table, &tag, &tagType, &value); err != nil {
+			return nil, err
+		}
+		if value == nil {
+			continue
+		}
+		if _, ok := tables[table]; !ok {
+			tables[table] = make(map[string]string)
+		}
+		tables[table][tag] = storedTagValue(*value, tagType, m.location())
+	}
+	return tables, rows.Err()
+}
+
+// ReadParam 查询一个子表的 Columns，From、To 不为 nil 时只查询时间戳列 TsColumn 在 [From, To) 内的行
+type ReadParam struct {
+	DBName    string
+	TableName string
+	Columns   []string
+	TsColumn  string
+	From      *time.Time
+	To        *time.Time
+}
+
+// ReadTable 按时间戳顺序查询子表，每行调用一次 fn，values 为驱动返回的值，fn 返回 error 时停止
+func (m *DatabaseAndTable) ReadTable(ctx context.Context, param ReadParam, fn func(values []any) error) error {
+	ql, err := readTableSql(param)
+	if err != nil {
+		return err
+	}
+	rows, err := m.conn.QueryContext(ctx, ql)
+	if err != nil {
+		return fmt.Errorf("query table [%s] error %v", param.TableName, err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	values := make([]any, len(param.Columns))
+	dest := make([]any, len(param.Columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	for rows.Next() {
+		if err = rows.Scan(dest...); err != nil {
+			return err
+		}
+		if err = fn(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func readTableSql(param ReadParam) (string, error) {
+	table, err := TableIdentifier(param.DBName, param.TableName)
+	if err != nil {
+		return "", err
+	}
+	columns := make([]string, 0, len(param.Columns))
+	for _, column := range param.Columns {
+		identifier, err := Identifier(column)
+		if err != nil {
+			return "", err
+		}
+		columns = append(columns, identifier)
+	}
+
+	var where []string
+	if param.From != nil || param.To != nil {
+		ts, err := Identifier(param.TsColumn)
+		if err != nil {
+			return "", err
+		}
+		if param.From != nil {
+			where = append(where, fmt.Sprintf("%s >= %s", ts, Quote(param.From.Format(time.RFC3339Nano))))
+		}
+		if param.To != nil {
+			where = append(where, fmt.Sprintf("%s < %s", ts, Quote(param.To.Format(time.RFC3339Nano))))
+		}
+	}
+
+	ql := fmt.Sprintf("select %s from %s", strings.Join(columns, ", "), table)
+	if len(where) > 0 {
+		ql += " where " + strings.Join(where, " and ")
+	}
+	return ql, nil
+}
+
+// SummaryParam 查询子表时间戳列 TsColumn 在 [From, To] 内的行数、第一个和最后一个时间戳以及 Sums 列的和
+type SummaryParam struct {
+	DBName    string
+	TableName string
+	TsColumn  string
+	Sums      []string
+	From      time.Time
+	To        time.Time
+}
+
+// Summary 没有数据时 First、Last 为 nil，列的值都为 null 时 sum 为 nil
+type Summary struct {
+	Rows  int64
+	First *time.Time
+	Last  *time.Time
+	Sums  map[string]*float64
+}
+
+func (m *DatabaseAndTable) TableSummary(ctx context.Context, param SummaryParam) (Summary, error) {
+	summary := Summary{Sums: make(map[string]*float64, len(param.Sums))}
+	ql, err := summarySql(param)
+	if err != nil {
+		return summary, err
+	}
+	rows, err := m.conn.QueryContext(ctx, ql)
+	if err != nil {
+		return summary, fmt.Errorf("query summary of table [%s] error %v", param.TableName, err)
+	}
+	defer func() { _ = rows.Close() }()
+	if !rows.Next() {
+		return summary, rows.Err()
+	}
+
+	values := make([]any, 3+len(param.Sums))
+	dest := make([]any, len(values))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err = rows.Scan(dest...); err != nil {
+		return summary, err
+	}
+	if values[0] != nil {
+		if summary.Rows, err = common.Int64(values[0]); err != nil {
+			return summary, fmt.Errorf("count of table [%s] %v", param.TableName, err)
+		}
+	}
+	for i, ts := range []**time.Time{&summary.First, &summary.Last} {
+		if t, ok := values[1+i].(time.Time); ok {
+			*ts = &t
+		}
+	}
+	for i, column := range param.Sums {
+		if values[3+i] == nil {
+			summary.Sums[column] = nil
+			continue
+		}
+		v, err := common.Float64(values[3+i])
+		if err != nil {
+			return summary, fmt.Errorf("sum of column [%s] %v", column, err)
+		}
+		summary.Sums[column] = &v
+	}
+	return summary, rows.Err()
+}
+
+func summarySql(param SummaryParam) (string, error) {
+	table, err := TableIdentifier(param.DBName, param.TableName)
+	if err != nil {
+		return "", err
+	}
+	ts, err := Identifier(param.TsColumn)
+	if err != nil {
+		return "", err
+	}
+	fields := []string{"count(*)", fmt.Sprintf("first(%s)", ts), fmt.Sprintf("last(%s)", ts)}
+	for _, column := range param.Sums {
+		identifier, err := Identifier(column)
+		if err != nil {
+			return "", err
+		}
+		fields = append(fields, fmt.Sprintf("sum(%s)", identifier))
+	}
+	return fmt.Sprintf("select %s from %s where %s >= %s and %s <= %s", strings.Join(fields, ", "), table,
+		ts, Quote(param.From.Format(time.RFC3339Nano)), ts, Quote(param.To.Format(time.RFC3339Nano))), nil
+}
+
+// storedTagValue ins_tags 中的时间戳没有时区，是客户端时区的时间，按 location 解析
+func storedTagValue(value string, tagType string, location *time.Location) string {
+	if common.BaseType(tagType) != common.TypeTimeStamp {
+		return value
+	}
+	t, err := common.ParseISO8601In(value, location)
+	if err != nil {
+		return value
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// TagDiff 比较 tag 文件中的值和已存储的值，返回不同的 tag，每个一行。值按 tag 类型转换后比较
+func TagDiff(param TableParam, stored map[string]string) []string {
+	diff := make([]string, 0)
+	for _, tv := range param.TagValues {
+		value, ok := stored[tv.TagName]
+		if tv.TagValue == nil && !ok {
+			continue
+		}
+		if ok && tv.TagValue != nil && equalValue(tv.TagValue, value, tv.TagValueType, param.Precision) {
+			continue
+		}
+
+		expect := "NULL"
+		if tv.TagValue != nil {
+			expect = common.String(tv.TagValue)
+		}
+		if !ok {
+			value = "NULL"
+		}
+		diff = append(diff, fmt.Sprintf("## table [%s] tag-[%s] stored-[%s] file-[%s]", param.TableName, tv.TagName, value, expect))
+	}
+	sort.Strings(diff)
+	return diff
+}
+
+func equalValue(a any, b string, columnType string, precision string) bool {
+	x, errX := common.Convert(a, columnType, precision)
+	y, errY := common.Convert(b, columnType, precision)
+	if errX != nil || errY != nil {
+		return common.String(a) == b
+	}
+	if t, ok := x.(time.Time); ok {
+		return t.Equal(y.(time.Time))
+	}
+	return common.String(x) == common.String(y)
+}
@@ -0,0 +1,144 @@
+package db_table
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTagDiff(t *testing.T) {
+	param := TableParam{
+		TableName: "d0",
+		Precision: "us",
+		TagValues: []TagValue{
+			{TagName: "ts_us", TagValue: "1669167000000000", TagValueType: "timestamp"},
+			{TagName: "code", TagValue: "1", TagValueType: "int"},
+			{TagName: "price", TagValue: "1.5", TagValueType: "double"},
+			{TagName: "name", TagValue: "银行", TagValueType: "nchar(10)"},
+			{TagName: "ts", TagValue: "2022-11-23T09:30:00+08:00", TagValueType: "timestamp"},
+			{TagName: "memo", TagValue: nil, TagValueType: "varchar(10)"},
+			{TagName: "area", TagValue: "SH", TagValueType: "varchar(10)"},
+		},
+	}
+	stored := map[string]string{
+		"code":  "01",
+		"price": "1.500000",
+		"name":  "银行 ",
+		"ts":    "2022-11-23T01:30:00Z",
+		"ts_us": "2022-11-23T01:30:00Z",
+	}
+
+	diff := TagDiff(param, stored)
+	expect := []string{
+		"## table [d0] tag-[area] stored-[NULL] file-[SH]",
+		"## table [d0] tag-[name] stored-[银行 ] file-[银行]",
+	}
+	if len(diff) != len(expect) {
+		t.Fatalf("expect-%v but got-%v", expect, diff)
+	}
+	for i := range diff {
+		if diff[i] != expect[i] {
+			t.Fatalf("expect-%v but got-%v", expect, diff)
+		}
+	}
+}
+
+func TestStoredTagValue(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		value    string
+		tagType  string
+		location *time.Location
+		expect   string
+	}{
+		{name: "utc", value: "2022-11-23 09:30:00.000", tagType: "TIMESTAMP", location: time.UTC, expect: "2022-11-23T09:30:00Z"},
+		{name: "client timezone", value: "2022-11-23 09:30:00.000", tagType: "TIMESTAMP", location: shanghai, expect: "2022-11-23T09:30:00+08:00"},
+		{name: "with zone", value: "2022-11-23T01:30:00Z", tagType: "TIMESTAMP", location: shanghai, expect: "2022-11-23T01:30:00Z"},
+		{name: "not timestamp", value: "2022-11-23 09:30:00.000", tagType: "VARCHAR(30)", location: shanghai, expect: "2022-11-23 09:30:00.000"},
+		{name: "invalid", value: "abc", tagType: "TIMESTAMP", location: shanghai, expect: "abc"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := storedTagValue(c.value, c.tagType, c.location); got != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, got)
+			}
+		})
+	}
+	stored := map[string]string{"ts": storedTagValue("2022-11-23 09:30:00.000", "TIMESTAMP", shanghai)}
+	param := TableParam{TableName: "d0", TagValues: []TagValue{{TagName: "ts", TagValue: "2022-11-23T01:30:00Z", TagValueType: "timestamp"}}}
+	if diff := TagDiff(param, stored); len(diff) > 0 {
+		t.Fatalf("same time in other timezone is different %v", diff)
+	}
+}
+
+func TestReadTableSql(t *testing.T) {
+	from := time.Date(2022, 11, 23, 1, 30, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	cases := []struct {
+		name   string
+		param  ReadParam
+		expect string
+		err    bool
+	}{
+		{
+			name:   "all",
+			param:  ReadParam{DBName: "stock", TableName: "t_1", Columns: []string{"ts", "price"}},
+			expect: "select `ts`, `price` from `stock`.`t_1`",
+		},
+		{
+			name:   "from",
+			param:  ReadParam{DBName: "stock", TableName: "t_1", Columns: []string{"ts"}, TsColumn: "ts", From: &from},
+			expect: "select `ts` from `stock`.`t_1` where `ts` >= '2022-11-23T01:30:00Z'",
+		},
+		{
+			name:   "range",
+			param:  ReadParam{DBName: "stock", TableName: "t_1", Columns: []string{"ts"}, TsColumn: "ts", From: &from, To: &to},
+			expect: "select `ts` from `stock`.`t_1` where `ts` >= '2022-11-23T01:30:00Z' and `ts` < '2022-11-23T02:30:00Z'",
+		},
+		{
+			name:  "no ts column",
+			param: ReadParam{DBName: "stock", TableName: "t_1", Columns: []string{"ts"}, To: &to},
+			err:   true,
+		},
+		{
+			name:  "illegal column",
+			param: ReadParam{DBName: "stock", TableName: "t_1", Columns: []string{"a`b"}},
+			err:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			ql, err := readTableSql(c.param)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if ql != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, ql)
+			}
+		})
+	}
+}
+
+func TestSummarySql(t *testing.T) {
+	from := time.Date(2022, 11, 23, 1, 30, 0, 0, time.UTC)
+	param := SummaryParam{DBName: "stock", TableName: "t_1", TsColumn: "ts", Sums: []string{"price", "volume"}, From: from, To: from.Add(time.Second)}
+	ql, err := summarySql(param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "select count(*), first(`ts`), last(`ts`), sum(`price`), sum(`volume`) from `stock`.`t_1` " +
+		"where `ts` >= '2022-11-23T01:30:00Z' and `ts` <= '2022-11-23T01:30:01Z'"
+	if ql != expect {
+		t.Fatalf("expect-[%s] but got-[%s]", expect, ql)
+	}
+
+	param.TsColumn = ""
+	if _, err = summarySql(param); err == nil {
+		t.Fatal("expect error of null ts column")
+	}
+}
@@ -7,17 +7,40 @@ import (
 	"fmt"
 	"log"
 	"strings"
-
-	_ "github.com/taosdata/driver-go/v3/taosSql"
+	"time"
 )
 
-func NewDatabaseAndTable(dbUri string) (*DatabaseAndTable, error) {
-	conn, err := sql.Open("taosSql", dbUri)
+// NewDatabaseAndTable 使用 driver 打开连接，driver 为 DriverNative、DriverWS 或 DriverRest
+func NewDatabaseAndTable(driver string, dbUri string) (*DatabaseAndTable, error) {
+	if !registered(driver) {
+		return nil, fmt.Errorf("driver [%s] is not built in", driver)
+	}
+	conn, err := sql.Open(driver, dbUri)
 	return &DatabaseAndTable{conn: conn}, err
 }
 
 type DatabaseAndTable struct {
 	conn *sql.DB
+	// Location 查询结果中没有时区的时间戳的时区，与客户端（ws、rest 时为 taosAdapter）的 timezone 相同，为 nil 时为本地时区
+	Location *time.Location
+	// MaxSqlLength 批量建表时一条 SQL 的最大长度，超过时拆分为多条，<= 0 时不拆分
+	MaxSqlLength int
+}
+
+func (m *DatabaseAndTable) location() *time.Location {
+	if m.Location == nil {
+		return time.Local
+	}
+	return m.Location
+}
+
+func (m *DatabaseAndTable) Close() error {
+	return m.conn.Close()
+}
+
+// Ordered 语句在 TDengine 中执行，可以并发调用
+func (m *DatabaseAndTable) Ordered() bool {
+	return false
 }
 
 func (m *DatabaseAndTable) CreateDB(ctx context.Context, param DBParam) error {
@@ -29,37 +52,95 @@ func (m *DatabaseAndTable) CreateDB(ctx context.Context, param DBParam) error {
 	return err
 }
 
-func (m *DatabaseAndTable) CreateSTableBySql(ctx context.Context, db string, sql string) error {
-	_, err := m.conn.ExecContext(ctx, fmt.Sprintf("use %s", db))
+// CreateSTable 超级表名带库名，不依赖连接当前使用的库
+func (m *DatabaseAndTable) CreateSTable(ctx context.Context, param STableParam) error {
+	ql, err := createSTableSql(param)
 	if err != nil {
 		return err
 	}
-	_, err = m.conn.ExecContext(ctx, sql)
+	_, err = m.conn.ExecContext(ctx, ql)
 	return err
 }
 
-func (m *DatabaseAndTable) CreateSTable(ctx context.Context, param STableParam) error {
-	return m.CreateSTableBySql(ctx, param.DBName, createSTableSql(param))
+// CreateTable 创建一个表，表名带库名，不依赖连接当前使用的库
+func (m *DatabaseAndTable) CreateTable(ctx context.Context, param TableParam) error {
+	tableSql, err := createTableSql(param)
+	if err != nil {
+		return err
+	}
+	if _, err = m.conn.ExecContext(ctx, tableSql); err != nil {
+		log.Printf("## create table by sql-[%s] error %v", tableSql, err)
+	}
+	return err
 }
 
-func (m *DatabaseAndTable) CreateTableBySql(ctx context.Context, db string, sql string) error {
-	_, err := m.conn.ExecContext(ctx, fmt.Sprintf("use %s", db))
+// CreateTables 用一条语句创建多个子表
+func (m *DatabaseAndTable) CreateTables(ctx context.Context, params []TableParam) error {
+	if len(params) == 0 {
+		return nil
+	}
+	sqls, err := createTablesSql(params, m.MaxSqlLength)
 	if err != nil {
 		return err
 	}
-	_, err = m.conn.ExecContext(ctx, sql)
-	return err
+	for _, tableSql := range sqls {
+		if _, err = m.conn.ExecContext(ctx, tableSql); err != nil {
+			return fmt.Errorf("create %d tables from [%s] to [%s] error %v", len(params), params[0].TableName, params[len(params)-1].TableName, err)
+		}
+	}
+	return nil
 }
 
-func (m *DatabaseAndTable) CreateTable(ctx context.Context, param TableParam) error {
-	tableSql := createTableSql(param)
-	err := m.CreateTableBySql(ctx, param.DBName, tableSql)
+// InsertFile 用 insert into ... file 导入 csv 文件，文件路径是执行语句的客户端上的路径
+func (m *DatabaseAndTable) InsertFile(ctx context.Context, db string, table string, file string) error {
+	ql, err := insertFileSql(db, table, file)
 	if err != nil {
-		log.Printf("## create table by sql-[%s] error %v", tableSql, err)
+		return err
 	}
+	_, err = m.conn.ExecContext(ctx, ql)
 	return err
 }
 
+// DeleteParam 删除子表时间戳列 TsColumn 在 [From, To] 内的行，From、To 都为 nil 时删除所有行
+type DeleteParam struct {
+	DBName    string
+	TableName string
+	TsColumn  string
+	From      *time.Time
+	To        *time.Time
+}
+
+func (m *DatabaseAndTable) DeleteRows(ctx context.Context, param DeleteParam) error {
+	ql, err := deleteSql(param)
+	if err != nil {
+		return err
+	}
+	if _, err = m.conn.ExecContext(ctx, ql); err != nil {
+		return fmt.Errorf("delete rows of table [%s] error %v", param.TableName, err)
+	}
+	return nil
+}
+
+// TopicParam 订阅超级表 Columns 列的主题，消费到的每行按 Columns 的顺序
+type TopicParam struct {
+	Topic      string
+	DBName     string
+	STableName string
+	Columns    []string
+}
+
+// CreateTopic 主题已存在时不修改
+func (m *DatabaseAndTable) CreateTopic(ctx context.Context, param TopicParam) error {
+	ql, err := createTopicSql(param)
+	if err != nil {
+		return err
+	}
+	if _, err = m.conn.ExecContext(ctx, ql); err != nil {
+		return fmt.Errorf("create topic [%s] error %v", param.Topic, err)
+	}
+	return nil
+}
+
 type DBParam struct {
 	DBName             string // dbname
 	Buffer             int    // 一个 VNODE 写入内存池大小, MB，默认为 96，
@@ -193,6 +274,7 @@ type TableParam struct {
 	TableName  string
 	Columns    []TableColumn
 	TagValues  []TagValue
+	Precision  string // db 的精度，整数时间戳 tag 按这个精度转换，默认 ms
 	Comment    string
 	Watermark  []string
 	MaxDelay   []string
@@ -201,24 +283,28 @@ type TableParam struct {
 	TTL        int
 }
 
-func createSTableSql(param STableParam) string {
-	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("create stable if not exists `%s` (", param.STableName))
-
-	for _, col := range param.Columns {
-		buffer.WriteString(fmt.Sprintf("`%s` %s, ", col.ColumnName, col.ColumnType))
+// createSTableSql create stable if not exists `db`.`stable` (columns) tags (tags)，DBName 为空时不带库名
+func createSTableSql(param STableParam) (string, error) {
+	stable, err := TableIdentifier(param.DBName, param.STableName)
+	if err != nil {
+		return "", err
+	}
+	if len(param.Columns) == 0 {
+		return "", fmt.Errorf("columns of stable [%s] is null", param.STableName)
 	}
-	buffer.Truncate(buffer.Len() - 2)
-	buffer.WriteString(") ")
+	columns, err := columnDefinitions(param.Columns)
+	if err != nil {
+		return "", err
+	}
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("create stable if not exists %s (%s) ", stable, columns))
 
 	if len(param.Tags) > 0 {
-		buffer.WriteString("tags (")
-
-		for _, tag := range param.Tags {
-			buffer.WriteString(fmt.Sprintf("`%s` %s, ", tag.ColumnName, tag.ColumnType))
+		tags, err := columnDefinitions(param.Tags)
+		if err != nil {
+			return "", err
 		}
-		buffer.Truncate(buffer.Len() - 2)
-		buffer.WriteString(") ")
+		buffer.WriteString(fmt.Sprintf("tags (%s) ", tags))
 	}
 
 	if len(param.Comment) > 0 {
@@ -240,51 +326,168 @@ func createSTableSql(param STableParam) string {
 		buffer.WriteString(fmt.Sprintf("ttl %d ", param.TTL))
 	}
 
-	return strings.Trim(buffer.String(), " ")
+	return strings.Trim(buffer.String(), " "), nil
+}
+
+// columnDefinitions `name` type, ...
+func columnDefinitions(columns []TableColumn) (string, error) {
+	definitions := make([]string, 0, len(columns))
+	for _, column := range columns {
+		name, err := Identifier(column.ColumnName)
+		if err != nil {
+			return "", err
+		}
+		definitions = append(definitions, name+" "+column.ColumnType)
+	}
+	return strings.Join(definitions, ", "), nil
 }
 
-func createTableSql(param TableParam) string {
+// createTablesSql 一条语句创建多个子表：create table if not exists a using s tags (...) if not exists b using s tags (...)，
+// 超过 maxSqlLength 时拆分为多条，maxSqlLength <= 0 时不拆分
+func createTablesSql(params []TableParam, maxSqlLength int) ([]string, error) {
+	const prefix = "create table"
+	var sqls []string
+	var buffer strings.Builder
+	for _, param := range params {
+		if len(param.STableName) == 0 {
+			return nil, fmt.Errorf("stable of table [%s] is null", param.TableName)
+		}
+		clause, err := createTableClause(param)
+		if err != nil {
+			return nil, err
+		}
+		if maxSqlLength > 0 && len(prefix)+1+len(clause) > maxSqlLength {
+			return nil, fmt.Errorf("sql of table [%s] is longer than max sql length %d", param.TableName, maxSqlLength)
+		}
+		if maxSqlLength > 0 && buffer.Len() > 0 && buffer.Len()+1+len(clause) > maxSqlLength {
+			sqls = append(sqls, buffer.String())
+			buffer.Reset()
+		}
+		if buffer.Len() == 0 {
+			buffer.WriteString(prefix)
+		}
+		buffer.WriteString(" ")
+		buffer.WriteString(clause)
+	}
+	if buffer.Len() > 0 {
+		sqls = append(sqls, buffer.String())
+	}
+	return sqls, nil
+}
+
+// createTableClause if not exists `db`.`table` using `db`.`stable` (tags) tags (values)，DBName 为空时不带库名
+func createTableClause(param TableParam) (string, error) {
 	var buffer bytes.Buffer
-	var tagBuffer bytes.Buffer
-	var tagValueBuffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("create table if not exists `%s` ", param.TableName))
+	table, err := TableIdentifier(param.DBName, param.TableName)
+	if err != nil {
+		return "", err
+	}
+	buffer.WriteString(fmt.Sprintf("if not exists %s", table))
 	if len(param.STableName) > 0 {
-		buffer.WriteString(fmt.Sprintf("using `%s` ", param.STableName))
+		stable, err := TableIdentifier(param.DBName, param.STableName)
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString(fmt.Sprintf(" using %s", stable))
 	}
 
 	if len(param.TagValues) > 0 {
-		tagBuffer.WriteString("(")
-		tagValueBuffer.WriteString("(")
+		names := make([]string, 0, len(param.TagValues))
+		values := make([]string, 0, len(param.TagValues))
 		for _, tv := range param.TagValues {
-			if tv.TagValue == nil || tv.TagValue == "" {
-				continue
+			name, err := Identifier(tv.TagName)
+			if err != nil {
+				return "", err
 			}
-
-			value := fmt.Sprintf("%s", tv.TagValue)
-			if strings.Contains(value, "'") {
-				value = strings.ReplaceAll(value, "'", "\\'")
+			value, err := Literal(tv.TagValue, tv.TagValueType, param.Precision)
+			if err != nil {
+				return "", fmt.Errorf("tag-[%s] of table [%s] %v", tv.TagName, param.TableName, err)
 			}
+			names = append(names, name)
+			values = append(values, value)
+		}
 
-			tagBuffer.WriteString(tv.TagName)
-			tagBuffer.WriteString(", ")
+		buffer.WriteString(fmt.Sprintf(" (%s) tags (%s)", strings.Join(names, ", "), strings.Join(values, ", ")))
+	}
+	return buffer.String(), nil
+}
 
-			if strings.HasPrefix(tv.TagValueType, "binary") || strings.HasPrefix(tv.TagValueType, "nchar") ||
-				strings.HasPrefix(tv.TagValueType, "varchar") || strings.HasPrefix(tv.TagValueType, "json") {
-				tagValueBuffer.WriteString(fmt.Sprintf("'%s'", value))
-			} else {
-				tagValueBuffer.WriteString(value)
-			}
-			tagValueBuffer.WriteString(", ")
+// TableIdentifier 返回 `db`.`table`，db 为空时只返回 `table`
+func TableIdentifier(db string, table string) (string, error) {
+	name, err := Identifier(table)
+	if err != nil || len(db) == 0 {
+		return name, err
+	}
+	dbName, err := Identifier(db)
+	if err != nil {
+		return "", err
+	}
+	return dbName + "." + name, nil
+}
+
+func deleteSql(param DeleteParam) (string, error) {
+	table, err := TableIdentifier(param.DBName, param.TableName)
+	if err != nil {
+		return "", err
+	}
+	if param.From == nil && param.To == nil {
+		return "delete from " + table, nil
+	}
+	ts, err := Identifier(param.TsColumn)
+	if err != nil {
+		return "", err
+	}
+	var where []string
+	if param.From != nil {
+		where = append(where, fmt.Sprintf("%s >= %s", ts, Quote(param.From.Format(time.RFC3339Nano))))
+	}
+	if param.To != nil {
+		where = append(where, fmt.Sprintf("%s <= %s", ts, Quote(param.To.Format(time.RFC3339Nano))))
+	}
+	return fmt.Sprintf("delete from %s where %s", table, strings.Join(where, " and ")), nil
+}
+
+func createTopicSql(param TopicParam) (string, error) {
+	topic, err := Identifier(param.Topic)
+	if err != nil {
+		return "", err
+	}
+	stable, err := TableIdentifier(param.DBName, param.STableName)
+	if err != nil {
+		return "", err
+	}
+	if len(param.Columns) == 0 {
+		return "", fmt.Errorf("columns of topic [%s] is null", param.Topic)
+	}
+	columns := make([]string, 0, len(param.Columns))
+	for _, column := range param.Columns {
+		name, err := Identifier(column)
+		if err != nil {
+			return "", err
 		}
-		tagBuffer.Truncate(tagBuffer.Len() - 2)
-		tagBuffer.WriteString(")")
-		tagValueBuffer.Truncate(tagValueBuffer.Len() - 2)
-		tagValueBuffer.WriteString(")")
+		columns = append(columns, name)
+	}
+	return fmt.Sprintf("create topic if not exists %s as select %s from %s", topic, strings.Join(columns, ", "), stable), nil
+}
+
+func insertFileSql(db string, table string, file string) (string, error) {
+	identifier, err := TableIdentifier(db, table)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("insert into %s file %s", identifier, Quote(file)), nil
+}
 
-		buffer.WriteString(tagBuffer.String())
-		buffer.WriteString(" tags ")
-		buffer.WriteString(tagValueBuffer.String())
+func createTableSql(param TableParam) (string, error) {
+	var buffer bytes.Buffer
+	clause, err := createTableClause(param)
+	if err != nil {
+		return "", err
 	}
+	buffer.WriteString("create table ")
+	buffer.WriteString(clause)
+	buffer.WriteString(" ")
+
 	if len(param.Columns) > 0 {
 		buffer.WriteString("(")
 		for _, col := range param.Columns {
@@ -313,5 +516,5 @@ func createTableSql(param TableParam) string {
 		buffer.WriteString(fmt.Sprintf("ttl %d ", param.TTL))
 	}
 
-	return strings.Trim(buffer.String(), " ")
+	return strings.Trim(buffer.String(), " "), nil
 }
@@ -1,7 +1,16 @@
 package db_table
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestDBManager_CreateDBSql(t *testing.T) {
@@ -38,6 +47,7 @@ func TestDBManager_CreateSTableSql(t *testing.T) {
 		name   string
 		param  STableParam
 		expect string
+		err    bool
 	}{
 		{
 			name: "1",
@@ -73,13 +83,47 @@ func TestDBManager_CreateSTableSql(t *testing.T) {
 					},
 				},
 			},
-			expect: "create stable if not exists `meters` (`ts` timestamp, `current` float, `voltage` int, `phase` float) tags (`location` varchar(64), `groupid` int)",
+			expect: "create stable if not exists `test`.`meters` (`ts` timestamp, `current` float, `voltage` int, `phase` float) tags (`location` varchar(64), `groupid` int)",
+		},
+		{
+			name: "escaped",
+			param: STableParam{
+				DBName:     "test",
+				STableName: "Quote",
+				Columns:    []TableColumn{{ColumnName: "ts", ColumnType: "timestamp"}, {ColumnName: "order", ColumnType: "int"}},
+			},
+			expect: "create stable if not exists `test`.`Quote` (`ts` timestamp, `order` int)",
+		},
+		{
+			name:  "no columns",
+			param: STableParam{DBName: "test", STableName: "meters"},
+			err:   true,
+		},
+		{
+			name: "invalid column",
+			param: STableParam{
+				DBName:     "test",
+				STableName: "meters",
+				Columns:    []TableColumn{{ColumnName: "t`s", ColumnType: "timestamp"}},
+			},
+			err: true,
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			if createSTableSql(c.param) != c.expect {
+			res, err := createSTableSql(c.param)
+			if c.err {
+				if err == nil {
+					t.Fatalf("expect error, but got %s", res)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Log(res)
 				t.Fatal("create stable sql error")
 			}
 		})
@@ -118,7 +162,7 @@ func TestDBManager_CreateTableSql(t *testing.T) {
 					{
 						TagName:      "location",
 						TagValue:     "California.SanFrancisco",
-						TagValueType: "string",
+						TagValueType: "varchar(64)",
 					},
 					{
 						TagName:      "groupid",
@@ -127,15 +171,250 @@ func TestDBManager_CreateTableSql(t *testing.T) {
 					},
 				},
 			},
-			expect: "create table if not exists `d0` using `stb1` (location, groupid) tags ('California.SanFrancisco', 1)",
+			expect: "create table if not exists `d0` using `stb1` (`location`, `groupid`) tags ('California.SanFrancisco', 1)",
+		},
+		{
+			name: "null",
+			param: TableParam{
+				STableName: "stb1",
+				TableName:  "d0",
+				TagValues: []TagValue{
+					{
+						TagName:      "location",
+						TagValue:     "",
+						TagValueType: "varchar(20)",
+					},
+					{
+						TagName:      "groupid",
+						TagValue:     nil,
+						TagValueType: "int",
+					},
+				},
+			},
+			expect: "create table if not exists `d0` using `stb1` (`location`, `groupid`) tags ('', NULL)",
 		},
 	}
 
 	for _, c := range cases {
 		t.Run(c.name, func(t *testing.T) {
-			if res := createTableSql(c.param); res != c.expect {
-				t.Fatal("create table sql error")
+			res, err := createTableSql(c.param)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("create table sql error. expect-[%s] but got-[%s]", c.expect, res)
 			}
 		})
 	}
 }
+
+func TestDBManager_CreateTablesSql(t *testing.T) {
+	params := []TableParam{
+		{
+			DBName:     "db",
+			STableName: "stb1",
+			TableName:  "d0",
+			TagValues:  []TagValue{{TagName: "groupid", TagValue: "1", TagValueType: "int"}},
+		},
+		{
+			DBName:     "db",
+			STableName: "stb1",
+			TableName:  "d1",
+			TagValues:  []TagValue{{TagName: "groupid", TagValue: 2, TagValueType: "int"}},
+		},
+	}
+	expect := "create table if not exists `db`.`d0` using `db`.`stb1` (`groupid`) tags (1) if not exists `db`.`d1` using `db`.`stb1` (`groupid`) tags (2)"
+
+	res, err := createTablesSql(params, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 1 || res[0] != expect {
+		t.Fatalf("create tables sql error. expect-[%s] but got-%v", expect, res)
+	}
+
+	// 超过最大长度时拆分，每条都不超过最大长度
+	first := "create table if not exists `db`.`d0` using `db`.`stb1` (`groupid`) tags (1)"
+	for _, max := range []int{len(first), len(expect) - 1} {
+		res, err = createTablesSql(params, max)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res) != 2 || res[0] != first || res[1] != "create table if not exists `db`.`d1` using `db`.`stb1` (`groupid`) tags (2)" {
+			t.Fatalf("split create tables sql error, got-%v", res)
+		}
+	}
+	if res, err = createTablesSql(params, len(expect)); err != nil || len(res) != 1 {
+		t.Fatalf("sql of max length should not be split, got-%v %v", res, err)
+	}
+	if _, err = createTablesSql(params, len(first)-1); err == nil {
+		t.Fatal("table longer than max sql length should fail")
+	}
+
+	if _, err = createTablesSql([]TableParam{{DBName: "db", TableName: "d0"}}, 0); err == nil {
+		t.Fatal("table without stable should fail")
+	}
+}
+
+func TestDBManager_DeleteSql(t *testing.T) {
+	from := time.Date(2022, 11, 23, 1, 30, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+	cases := []struct {
+		name   string
+		param  DeleteParam
+		expect string
+		err    bool
+	}{
+		{
+			name:   "all",
+			param:  DeleteParam{DBName: "db", TableName: "d0"},
+			expect: "delete from `db`.`d0`",
+		},
+		{
+			name:   "range",
+			param:  DeleteParam{DBName: "db", TableName: "d0", TsColumn: "ts", From: &from, To: &to},
+			expect: "delete from `db`.`d0` where `ts` >= '2022-11-23T01:30:00Z' and `ts` <= '2022-11-23T02:30:00Z'",
+		},
+		{
+			name:   "to",
+			param:  DeleteParam{DBName: "db", TableName: "d0", TsColumn: "ts", To: &to},
+			expect: "delete from `db`.`d0` where `ts` <= '2022-11-23T02:30:00Z'",
+		},
+		{
+			name:  "no ts column",
+			param: DeleteParam{DBName: "db", TableName: "d0", From: &from},
+			err:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := deleteSql(c.param)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if res != c.expect {
+				t.Fatalf("delete sql error. expect-[%s] but got-[%s]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestDBManager_CreateTopicSql(t *testing.T) {
+	cases := []struct {
+		name   string
+		param  TopicParam
+		expect string
+		err    bool
+	}{
+		{
+			name:   "columns",
+			param:  TopicParam{Topic: "t_meters", DBName: "db", STableName: "meters", Columns: []string{"ts", "current"}},
+			expect: "create topic if not exists `t_meters` as select `ts`, `current` from `db`.`meters`",
+		},
+		{
+			name:  "no columns",
+			param: TopicParam{Topic: "t_meters", DBName: "db", STableName: "meters"},
+			err:   true,
+		},
+		{
+			name:  "no topic",
+			param: TopicParam{DBName: "db", STableName: "meters", Columns: []string{"ts"}},
+			err:   true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := createTopicSql(c.param)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if res != c.expect {
+				t.Fatalf("create topic sql error. expect-[%s] but got-[%s]", c.expect, res)
+			}
+		})
+	}
+}
+
+// statelessRest 模拟 taosAdapter 的 REST 接口：每个请求独立，use 不影响之后的请求，
+// url 中没有库名时语句中的表需要带库名
+type statelessRest struct {
+	mu   sync.Mutex
+	dbs  map[string]bool
+	sqls []string
+}
+
+var (
+	createDBPattern     = regexp.MustCompile("^create database if not exists `([^`]+)`")
+	createSTablePattern = regexp.MustCompile("^create stable if not exists (?:`([^`]+)`\\.)?`[^`]+`")
+)
+
+func (f *statelessRest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.URL.Path, "/rest/sql") || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	ql := string(body)
+	db := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/rest/sql"), "/")
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.sqls = append(f.sqls, ql)
+
+	if m := createDBPattern.FindStringSubmatch(ql); m != nil {
+		f.dbs[m[1]] = true
+	} else if m = createSTablePattern.FindStringSubmatch(ql); m != nil {
+		if len(m[1]) > 0 {
+			db = m[1]
+		}
+		if len(db) == 0 {
+			_, _ = fmt.Fprint(w, `{"code":9750,"desc":"Database not specified"}`)
+			return
+		}
+		if !f.dbs[db] {
+			_, _ = fmt.Fprint(w, `{"code":904,"desc":"Database not exist"}`)
+			return
+		}
+	}
+	_, _ = fmt.Fprint(w, `{"code":0,"column_meta":[["affected_rows","INT",4]],"data":[[0]],"rows":1}`)
+}
+
+func TestDatabaseAndTable_CreateOverRest(t *testing.T) {
+	adapter := &statelessRest{dbs: make(map[string]bool)}
+	server := httptest.NewServer(adapter)
+	defer server.Close()
+
+	dt, err := NewDatabaseAndTable(DriverRest, fmt.Sprintf("root:taosdata@http(%s)/", server.Listener.Addr().String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = dt.Close() }()
+
+	ctx := context.Background()
+	stable := STableParam{
+		DBName:     "stock",
+		STableName: "quote",
+		Columns:    []TableColumn{{ColumnName: "ts", ColumnType: "timestamp"}, {ColumnName: "price", ColumnType: "double"}},
+		Tags:       []TableColumn{{ColumnName: "code", ColumnType: "varchar(10)"}},
+	}
+	if err = dt.CreateSTable(ctx, stable); err == nil {
+		t.Fatal("create stable before database should fail")
+	}
+	if err = dt.CreateDB(ctx, DBParam{DBName: "stock", Precision: "ms"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = dt.CreateSTable(ctx, stable); err != nil {
+		t.Fatal(err)
+	}
+	tables := []TableParam{{DBName: "stock", STableName: "quote", TableName: "t_1", TagValues: []TagValue{{TagName: "code", TagValue: "600000", TagValueType: "varchar(10)"}}}}
+	if err = dt.CreateTables(ctx, tables); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ql := range adapter.sqls {
+		if strings.HasPrefix(ql, "use ") {
+			t.Fatalf("rest requests are stateless, but got %s", ql)
+		}
+	}
+}
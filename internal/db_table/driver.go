@@ -0,0 +1,23 @@
+package db_table
+
+import (
+	"database/sql"
+
+	_ "github.com/taosdata/driver-go/v3/taosRestful"
+	_ "github.com/taosdata/driver-go/v3/taosWS"
+)
+
+const (
+	DriverNative = "taosSql"     // cgo 驱动，purego 编译时不包含
+	DriverWS     = "taosWS"      // taosAdapter websocket
+	DriverRest   = "taosRestful" // taosAdapter REST
+)
+
+func registered(driver string) bool {
+	for _, d := range sql.Drivers() {
+		if d == driver {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,7 @@
+//go:build !purego
+
+package db_table
+
+import (
+	_ "github.com/taosdata/driver-go/v3/taosSql"
+)
@@ -0,0 +1,65 @@
+package db_table
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"taos_importer/internal/common"
+	"time"
+)
+
+// Literal 按 TDengine 类型把 value 渲染为 SQL 字面量，nil 渲染为 NULL。
+// 字符串按单引号转义，timestamp 渲染为带时区的 RFC3339 字符串，整数时间戳按 db 的 precision 转换，varbinary 渲染为 '\x...'
+func Literal(value any, columnType string, precision string) (string, error) {
+	t := common.BaseType(columnType)
+	v, err := common.Convert(value, t, precision)
+	if err != nil {
+		return "", err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return "NULL", nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return Quote(v.Format(time.RFC3339Nano)), nil
+	case []byte:
+		if t == common.TypeVarbinary {
+			return `'\x` + hex.EncodeToString(v) + `'`, nil
+		}
+		return Quote(string(v)), nil
+	case string:
+		if t == common.TypeJson && !json.Valid([]byte(v)) {
+			return "", fmt.Errorf("value [%s] is not a json", v)
+		}
+		return Quote(v), nil
+	default:
+		return "", fmt.Errorf("unknown type %T ", v)
+	}
+}
+
+// Quote 单引号字符串，转义反斜杠和单引号
+func Quote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s + "'"
+}
+
+// Identifier 用反引号包含的名称，名称不能为空且不能包含反引号
+func Identifier(name string) (string, error) {
+	if len(name) == 0 || strings.ContainsAny(name, "`\x00") {
+		return "", fmt.Errorf("illegal name [%s]", name)
+	}
+	return "`" + name + "`", nil
+}
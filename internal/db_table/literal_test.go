@@ -0,0 +1,82 @@
+package db_table
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLiteral(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      any
+		columnType string
+		precision  string
+		expect     string
+	}{
+		{name: "int", value: int64(100), columnType: "int", expect: "100"},
+		{name: "int string", value: "100", columnType: "bigint", expect: "100"},
+		{name: "unsigned", value: "18446744073709551615", columnType: "bigint unsigned", expect: "18446744073709551615"},
+		{name: "double", value: 1.5, columnType: "double", expect: "1.5"},
+		{name: "bool", value: "true", columnType: "bool", expect: "true"},
+		{name: "nil", value: nil, columnType: "nchar(10)", expect: "NULL"},
+		{name: "escape", value: `it's a\b`, columnType: "varchar(20)", expect: `'it\'s a\\b'`},
+		{name: "nchar", value: "银行", columnType: "nchar(10)", expect: "'银行'"},
+		{name: "json", value: `{"k":"v"}`, columnType: "json", expect: `'{"k":"v"}'`},
+		{name: "varbinary", value: "0x0aff", columnType: "varbinary(10)", expect: `'\x0aff'`},
+		{
+			name:       "timestamp",
+			value:      time.Date(2022, 11, 23, 9, 30, 0, 100000000, time.FixedZone("CST", 8*3600)),
+			columnType: "timestamp",
+			expect:     "'2022-11-23T09:30:00.1+08:00'",
+		},
+		{
+			name:       "integer timestamp ms",
+			value:      "1669167985100",
+			columnType: "timestamp",
+			expect:     "'" + time.UnixMilli(1669167985100).Format(time.RFC3339Nano) + "'",
+		},
+		{
+			name:       "integer timestamp us",
+			value:      "1669167985100000",
+			columnType: "timestamp",
+			precision:  "us",
+			expect:     "'" + time.UnixMicro(1669167985100000).Format(time.RFC3339Nano) + "'",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := Literal(c.value, c.columnType, c.precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestLiteral_Error(t *testing.T) {
+	cases := []struct {
+		name       string
+		value      any
+		columnType string
+	}{
+		{name: "int", value: "1; drop database db", columnType: "int"},
+		{name: "json", value: "{", columnType: "json"},
+		{name: "type", value: "a", columnType: "string"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if res, err := Literal(c.value, c.columnType, ""); err == nil {
+				t.Fatalf("expect error but got-[%s]", res)
+			}
+		})
+	}
+
+	if _, err := Identifier("a`b"); err == nil {
+		t.Fatal("illegal identifier should fail")
+	}
+}
@@ -0,0 +1,101 @@
+package db_table
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"taos_importer/internal/common"
+)
+
+const (
+	CollisionFirstWins = "first_wins" // 保留第一次出现的 tags，后面冲突的行不创建子表
+	CollisionError     = "error"      // 出现冲突时报错
+	CollisionSuffix    = "suffix"     // 冲突的行使用 <name>_2、<name>_3 ... 作为子表名
+)
+
+// NameRegistry 检查不同 tag 行生成的子表名是否冲突，tags 完全相同的重复行不算冲突
+type NameRegistry struct {
+	mu        sync.Mutex
+	policy    string
+	names     map[string]nameEntry // 子表名 -> 第一次出现的行
+	conflicts []string
+}
+
+type nameEntry struct {
+	fingerprint string
+	source      string
+}
+
+func NewNameRegistry(policy string) (*NameRegistry, error) {
+	switch policy {
+	case "":
+		policy = CollisionFirstWins
+	case CollisionFirstWins, CollisionError, CollisionSuffix:
+	default:
+		return nil, fmt.Errorf("unknown name collision policy [%s]", policy)
+	}
+	return &NameRegistry{policy: policy, names: make(map[string]nameEntry)}, nil
+}
+
+// Register 登记 source（如 file:line）中 tags 生成的子表名 name，返回要创建的子表名。
+// 返回空字符串表示不需要创建（重复行或 first_wins 下的冲突行），policy 为 error 时冲突返回 error
+func (r *NameRegistry) Register(name string, tags []TagValue, source string) (string, error) {
+	fingerprint := tagFingerprint(tags)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	first, ok := r.names[name]
+	if !ok {
+		r.names[name] = nameEntry{fingerprint: fingerprint, source: source}
+		return name, nil
+	}
+	if first.fingerprint == fingerprint {
+		return "", nil
+	}
+
+	conflict := fmt.Sprintf("## table [%s] of [%s] conflicts with [%s]. tags-[%s] and tags-[%s]", name, source, first.source, fingerprint, first.fingerprint)
+	r.conflicts = append(r.conflicts, conflict)
+
+	switch r.policy {
+	case CollisionError:
+		return "", fmt.Errorf("table name conflict. %s", strings.TrimPrefix(conflict, "## "))
+	case CollisionSuffix:
+		for i := 2; ; i++ {
+			suffixed := fmt.Sprintf("%s_%d", name, i)
+			entry, ok := r.names[suffixed]
+			if !ok {
+				r.names[suffixed] = nameEntry{fingerprint: fingerprint, source: source}
+				return suffixed, nil
+			}
+			if entry.fingerprint == fingerprint {
+				return "", nil
+			}
+		}
+	default:
+		return "", nil
+	}
+}
+
+// Conflicts 返回所有冲突，每个一行
+func (r *NameRegistry) Conflicts() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	conflicts := append([]string(nil), r.conflicts...)
+	sort.Strings(conflicts)
+	return conflicts
+}
+
+func tagFingerprint(tags []TagValue) string {
+	pairs := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		value := "NULL"
+		if tag.TagValue != nil {
+			value = common.String(tag.TagValue)
+		}
+		pairs = append(pairs, tag.TagName+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
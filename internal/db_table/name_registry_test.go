@@ -0,0 +1,55 @@
+package db_table
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNameRegistry(t *testing.T) {
+	a := []TagValue{{TagName: "code", TagValue: "600000"}}
+	b := []TagValue{{TagName: "code", TagValue: "600001"}}
+
+	cases := []struct {
+		name   string
+		policy string
+		expect []string // 依次登记 a, a, b, b 返回的表名
+		fail   bool
+	}{
+		{name: "first_wins", policy: CollisionFirstWins, expect: []string{"t_600", "", "", ""}},
+		{name: "suffix", policy: CollisionSuffix, expect: []string{"t_600", "", "t_600_2", ""}},
+		{name: "error", policy: CollisionError, expect: []string{"t_600", ""}, fail: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r, err := NewNameRegistry(c.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var res []string
+			for i, tags := range [][]TagValue{a, a, b, b} {
+				name, err := r.Register("t_600", tags, fmt.Sprintf("tag.csv:%d", i+1))
+				if err != nil {
+					break
+				}
+				res = append(res, name)
+			}
+			if len(res) != len(c.expect) {
+				t.Fatalf("expect-%v but got-%v", c.expect, res)
+			}
+			for i := range res {
+				if res[i] != c.expect[i] {
+					t.Fatalf("expect-%v but got-%v", c.expect, res)
+				}
+			}
+			if len(r.Conflicts()) == 0 {
+				t.Fatal("conflict should be reported")
+			}
+		})
+	}
+
+	if _, err := NewNameRegistry("last_wins"); err == nil {
+		t.Fatal("unknown policy should fail")
+	}
+}
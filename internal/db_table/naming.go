@@ -0,0 +1,118 @@
+package db_table
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const (
+	NameCaseLower = "lower"
+	NameCaseUpper = "upper"
+	NameCaseKeep  = "keep"
+
+	// MaxTableNameLength TDengine 表名的最大长度
+	MaxTableNameLength = 192
+
+	nameHashLength = 8
+)
+
+// NamingPolicy 子表名的规范化规则，tag 文件生成的表名和数据文件名得到的表名使用同一规则。
+// 只保留字母、数字和下划线，其他字符替换为 Replace；数字开头时加 t_ 前缀；
+// 超过 MaxLength 时截断并加上原名 md5 的前 8 位，避免截断后冲突
+type NamingPolicy struct {
+	Case      string // lower | upper | keep, 默认 lower。STMT 写入时表名不带反引号，TDengine 会转为小写
+	Replace   string // 非法字符的替换字符串, 默认 _
+	MaxLength int    // 默认 192，不小于 nameHashLength+2
+}
+
+// maxLength 返回表名的最大长度，MaxLength 放不下截断后的 hash 时返回 error
+func (p NamingPolicy) maxLength() (int, error) {
+	if p.MaxLength <= 0 || p.MaxLength > MaxTableNameLength {
+		return MaxTableNameLength, nil
+	}
+	if p.MaxLength < nameHashLength+2 {
+		return 0, fmt.Errorf("max_length [%d] of table name should be in [%d, %d]", p.MaxLength, nameHashLength+2, MaxTableNameLength)
+	}
+	return p.MaxLength, nil
+}
+
+// Normalize 返回规范化后的表名，名称为空时返回 error
+func (p NamingPolicy) Normalize(name string) (string, error) {
+	original := name
+	max, err := p.maxLength()
+	if err != nil {
+		return "", err
+	}
+	name = strings.TrimSpace(name)
+	if len(name) == 0 {
+		return "", fmt.Errorf("table name is null")
+	}
+
+	switch p.Case {
+	case "", NameCaseLower:
+		name = strings.ToLower(name)
+	case NameCaseUpper:
+		name = strings.ToUpper(name)
+	case NameCaseKeep:
+	default:
+		return "", fmt.Errorf("unknown name case [%s]", p.Case)
+	}
+
+	replace := p.Replace
+	if len(replace) == 0 {
+		replace = "_"
+	}
+	var builder strings.Builder
+	for _, r := range name {
+		if isNameChar(r) {
+			builder.WriteRune(r)
+		} else {
+			builder.WriteString(replace)
+		}
+	}
+	name = builder.String()
+	if name[0] >= '0' && name[0] <= '9' {
+		name = "t_" + name
+	}
+
+	if len(name) > max {
+		sum := md5.Sum([]byte(original))
+		name = name[:max-nameHashLength-1] + "_" + hex.EncodeToString(sum[:])[:nameHashLength]
+	}
+
+	return name, p.Validate(name)
+}
+
+// Validate 检查表名是否符合规则
+func (p NamingPolicy) Validate(name string) error {
+	max, err := p.maxLength()
+	if err != nil {
+		return err
+	}
+	if len(name) == 0 || len(name) > max {
+		return fmt.Errorf("length of table name [%s] should be in [1, %d]", name, max)
+	}
+	if name[0] >= '0' && name[0] <= '9' {
+		return fmt.Errorf("table name [%s] starts with a digit", name)
+	}
+	for _, r := range name {
+		if !isNameChar(r) {
+			return fmt.Errorf("table name [%s] has illegal char [%c]", name, r)
+		}
+	}
+	return nil
+}
+
+// TableNameFromFile 数据文件对应的子表名：前缀加去掉扩展名的文件名，再按规则规范化
+func (p NamingPolicy) TableNameFromFile(file string, prefix string) (string, error) {
+	_, fileName := path.Split(file)
+	fileName = strings.TrimSuffix(fileName, path.Ext(fileName))
+	return p.Normalize(prefix + fileName)
+}
+
+func isNameChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,75 @@
+package db_table
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNamingPolicy_Normalize(t *testing.T) {
+	long := strings.Repeat("a", 200)
+
+	cases := []struct {
+		name   string
+		policy NamingPolicy
+		input  string
+		expect string
+	}{
+		{name: "lower", input: "T_600000.SH", expect: "t_600000_sh"},
+		{name: "keep", policy: NamingPolicy{Case: NameCaseKeep}, input: " T 600000 ", expect: "T_600000"},
+		{name: "upper", policy: NamingPolicy{Case: NameCaseUpper, Replace: "x"}, input: "a-b", expect: "AxB"},
+		{name: "digit", input: "600000", expect: "t_600000"},
+		{name: "unicode", input: "t_银行", expect: "t___"},
+		{name: "max length", policy: NamingPolicy{MaxLength: 20}, input: "t_" + long, expect: "t_aaaaaaaaa_fdc319d4"},
+		{name: "default max length", input: long, expect: strings.Repeat("a", 183) + "_887f30b4"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := c.policy.Normalize(c.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(c.expect) > 0 && res != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, res)
+			}
+		})
+	}
+
+	if _, err := (NamingPolicy{}).Normalize(" "); err == nil {
+		t.Fatal("empty name should fail")
+	}
+	for _, max := range []int{1, 9} {
+		if _, err := (NamingPolicy{MaxLength: max}).Normalize("t_" + long); err == nil {
+			t.Fatalf("max length %d should fail", max)
+		}
+	}
+	if res, err := (NamingPolicy{MaxLength: 10}).Normalize("t_" + long); err != nil || res != "t_fdc319d4" {
+		t.Fatalf("min max length error, got-[%s] %v", res, err)
+	}
+}
+
+func TestNamingPolicy_TableNameFromFile(t *testing.T) {
+	cases := []struct {
+		name   string
+		file   string
+		prefix string
+		expect string
+	}{
+		{name: "1", file: "/data/t_1.csv", expect: "t_1"},
+		{name: "2", file: "/data/abc.csv", prefix: "t_", expect: "t_abc"},
+		{name: "3", file: "data/600000.SH.csv", prefix: "t_", expect: "t_600000_sh"},
+		{name: "4", file: "/data/vcs.csv", expect: "vcs"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := NamingPolicy{}.TableNameFromFile(c.file, c.prefix)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, res)
+			}
+		})
+	}
+}
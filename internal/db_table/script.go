@@ -0,0 +1,97 @@
+package db_table
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"sync"
+)
+
+// Script 把建库、建表语句写入 SQL 文件，之后用 taos -f 执行。方法与 DatabaseAndTable 相同，每条语句一行
+type Script struct {
+	mu     sync.Mutex
+	file   *os.File
+	writer *bufio.Writer
+	// MaxSqlLength 批量建表时一条 SQL 的最大长度，超过时拆分为多条，<= 0 时不拆分
+	MaxSqlLength int
+}
+
+func NewScript(file string) (*Script, error) {
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{file: f, writer: bufio.NewWriter(f)}, nil
+}
+
+func (s *Script) CreateDB(_ context.Context, param DBParam) error {
+	ql, err := createDBSql(param)
+	if err != nil {
+		return err
+	}
+	return s.write(ql)
+}
+
+func (s *Script) CreateSTable(_ context.Context, param STableParam) error {
+	ql, err := createSTableSql(param)
+	if err != nil {
+		return err
+	}
+	return s.write(ql)
+}
+
+// CreateTables 用一条语句创建多个子表，超过 MaxSqlLength 时拆分为多条
+func (s *Script) CreateTables(_ context.Context, params []TableParam) error {
+	if len(params) == 0 {
+		return nil
+	}
+	sqls, err := createTablesSql(params, s.MaxSqlLength)
+	if err != nil {
+		return err
+	}
+	for _, tableSql := range sqls {
+		if err = s.write(tableSql); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExistingTables 脚本不查询已有的子表，返回空
+func (s *Script) ExistingTables(_ context.Context, _ string, _ string) (map[string]map[string]string, error) {
+	return make(map[string]map[string]string), nil
+}
+
+// Ordered 语句按调用的顺序写入文件，需要按顺序调用才能得到确定的输出
+func (s *Script) Ordered() bool {
+	return true
+}
+
+func (s *Script) InsertFile(_ context.Context, db string, table string, file string) error {
+	ql, err := insertFileSql(db, table, file)
+	if err != nil {
+		return err
+	}
+	return s.write(ql)
+}
+
+func (s *Script) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.writer.Flush(); err != nil {
+		_ = s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+func (s *Script) write(sqls ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, sql := range sqls {
+		if _, err := s.writer.WriteString(sql + ";\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package db_table
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScript(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "schema.sql")
+	s, err := NewScript(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = s.CreateDB(ctx, DBParam{DBName: "stock", Precision: "ms"}); err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateSTable(ctx, STableParam{
+		DBName:     "stock",
+		STableName: "quote",
+		Columns:    []TableColumn{{ColumnName: "ts", ColumnType: "timestamp"}, {ColumnName: "price", ColumnType: "double"}},
+		Tags:       []TableColumn{{ColumnName: "code", ColumnType: "varchar(10)"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = s.CreateTables(ctx, []TableParam{
+		{DBName: "stock", STableName: "quote", TableName: "t_1", TagValues: []TagValue{{TagName: "code", TagValue: "a'1", TagValueType: "varchar(10)"}}},
+		{DBName: "stock", STableName: "quote", TableName: "t_2", TagValues: []TagValue{{TagName: "code", TagValue: nil, TagValueType: "varchar(10)"}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.InsertFile(ctx, "stock", "t_1", "/data/t_1.csv"); err != nil {
+		t.Fatal(err)
+	}
+	if err = s.CreateTables(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+	if existing, err := s.ExistingTables(ctx, "stock", "quote"); err != nil || len(existing) != 0 {
+		t.Fatalf("existing tables %v %v", existing, err)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := "create database if not exists `stock` precision ms;\n" +
+		"create stable if not exists `stock`.`quote` (`ts` timestamp, `price` double) tags (`code` varchar(10));\n" +
+		"create table if not exists `stock`.`t_1` using `stock`.`quote` (`code`) tags ('a\\'1') " +
+		"if not exists `stock`.`t_2` using `stock`.`quote` (`code`) tags (NULL);\n" +
+		"insert into `stock`.`t_1` file '/data/t_1.csv';\n"
+	if string(b) != expect {
+		t.Errorf("\n%s\nexpect\n%s", b, expect)
+	}
+}
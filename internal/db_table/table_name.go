@@ -8,6 +8,8 @@ import (
 	"taos_importer/internal/field"
 )
 
+const NameHashKeyed = "keyed"
+
 func GenerateTableName(pattern string, tags map[string]any) (string, error) {
 	if len(pattern) == 0 {
 		return generateTableNameByTags(tags), nil
@@ -31,3 +33,24 @@ func generateTableNameByTags(tags map[string]any) string {
 
 	return "t_" + hex.EncodeToString(m.Sum(nil))
 }
+
+// HashTableName 用 salt（一般为超级表名）和排序后的 key=value 计算 md5。
+// 与 generateTableNameByTags 不同，值相同但 key 不同的 tags 不会得到相同的表名，不同超级表也不会冲突
+func HashTableName(salt string, tags map[string]any) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	m := md5.New()
+	m.Write([]byte(salt))
+	for _, k := range keys {
+		m.Write([]byte{0})
+		m.Write([]byte(k))
+		m.Write([]byte{'='})
+		m.Write([]byte(common.String(tags[k])))
+	}
+
+	return "t_" + hex.EncodeToString(m.Sum(nil))
+}
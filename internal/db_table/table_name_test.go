@@ -49,3 +49,16 @@ func TestTimeFormat(t *testing.T) {
 	t.Log(time.Parse("20060102150405.000", "20221123094625.100"))
 	t.Log(time.Parse("20060102150405.000", "20221123094625100"))
 }
+
+func TestHashTableName(t *testing.T) {
+	a := HashTableName("stb1", map[string]any{"a": "1", "b": "2"})
+	if a != HashTableName("stb1", map[string]any{"b": "2", "a": "1"}) {
+		t.Fatal("hash should not depend on key order")
+	}
+	if a == HashTableName("stb1", map[string]any{"a": "2", "b": "1"}) {
+		t.Fatal("hash should include keys")
+	}
+	if a == HashTableName("stb2", map[string]any{"a": "1", "b": "2"}) {
+		t.Fatal("hash should include salt")
+	}
+}
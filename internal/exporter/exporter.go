@@ -0,0 +1,285 @@
+package exporter
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"taos_importer/internal/field"
+	"time"
+)
+
+const (
+	// TagsFile 导出目录下的 tag 文件
+	TagsFile = "tags.csv"
+	// DataDir 导出目录下数据文件所在的目录
+	DataDir = "data"
+)
+
+// Source 导出的数据来源，DatabaseAndTable 查询 TDengine
+type Source interface {
+	ExistingTables(ctx context.Context, db string, stable string) (map[string]map[string]string, error)
+	ReadTable(ctx context.Context, param db_table.ReadParam, fn func(values []any) error) error
+}
+
+// Options 导出的范围
+type Options struct {
+	Dir    string
+	From   string // 只导出时间戳在 [From, To) 内的行，ISO-8601 或 db 精度的整数时间戳
+	To     string
+	Filter string // tag 的过滤表达式，结果为 false 的子表不导出
+}
+
+// CsvExporter 按 [stable] 的列和 tags 把超级表导出为 csv，导出的目录可以直接用于 import：
+// 每个子表一个 data/<文件名>.csv，文件名为子表名去掉 child_table_name_prefix，tag 写入 tags.csv。
+// 表头为 source，source 是表达式时使用列名
+type CsvExporter struct {
+	conf      config.Config
+	source    Source
+	dir       string
+	from      *time.Time
+	to        *time.Time
+	filter    string
+	extractor *field.Extractor
+
+	Tables atomic.Int64
+	Failed atomic.Int64
+	Rows   atomic.Int64
+}
+
+func NewCsvExporter(conf config.Config, source Source, options Options) (*CsvExporter, error) {
+	if len(options.Dir) == 0 {
+		return nil, fmt.Errorf("export dir is null")
+	}
+	if len(conf.STable.Columns) == 0 {
+		return nil, fmt.Errorf("columns of stable [%s] is null", conf.STable.Name)
+	}
+	e := &CsvExporter{
+		conf:      conf,
+		source:    source,
+		dir:       options.Dir,
+		filter:    options.Filter,
+		extractor: field.NewExtractor(),
+	}
+	var err error
+	if e.from, err = timeBound(options.From, conf.DB.Precision); err != nil {
+		return nil, err
+	}
+	if e.to, err = timeBound(options.To, conf.DB.Precision); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Export 导出所有匹配的子表，返回每个子表的结果，按子表名排序
+func (e *CsvExporter) Export(ctx context.Context) ([]string, error) {
+	stored, err := e.source.ExistingTables(ctx, e.conf.DB.Name, e.conf.STable.Name)
+	if err != nil {
+		return nil, err
+	}
+	tables := make([]string, 0, len(stored))
+	for table, tags := range stored {
+		ok, err := e.match(tags)
+		if err != nil {
+			return nil, fmt.Errorf("table [%s] %v", table, err)
+		}
+		if ok {
+			tables = append(tables, table)
+		}
+	}
+	sort.Strings(tables)
+
+	if err = os.MkdirAll(filepath.Join(e.dir, DataDir), 0755); err != nil {
+		return nil, err
+	}
+	if err = e.writeTags(tables, stored); err != nil {
+		return nil, err
+	}
+
+	results := make([]string, len(tables))
+	ch := make(chan int)
+	var wait sync.WaitGroup
+	go func() {
+		defer close(ch)
+		for i := range tables {
+			ch <- i
+		}
+	}()
+	workers := e.conf.Concurrent
+	if workers <= 0 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range ch {
+				results[i] = e.exportTable(ctx, tables[i])
+			}
+		}()
+	}
+	wait.Wait()
+	return results, nil
+}
+
+// match 用 tag 值计算 filter 表达式，null 的 tag 为空字符串，未配置 filter 时总是返回 true
+func (e *CsvExporter) match(tags map[string]string) (bool, error) {
+	if len(e.filter) == 0 {
+		return true, nil
+	}
+	data := make(map[string]any, len(e.conf.STable.Tags))
+	for _, tag := range e.conf.STable.Tags {
+		data[tag.Field] = ""
+	}
+	for k, v := range tags {
+		data[k] = v
+	}
+	value, err := e.extractor.Extract(e.filter, data)
+	if err != nil {
+		return false, fmt.Errorf("filter %v", err)
+	}
+	ok, err := common.Bool(value)
+	if err != nil {
+		return false, fmt.Errorf("filter result [%v] is not bool", value)
+	}
+	return ok, nil
+}
+
+// writeTags 每个子表一行，null 的 tag 为空
+func (e *CsvExporter) writeTags(tables []string, stored map[string]map[string]string) error {
+	header := make([]string, 0, len(e.conf.STable.Tags))
+	for _, tag := range e.conf.STable.Tags {
+		header = append(header, columnHeader(tag))
+	}
+	return writeCsv(filepath.Join(e.dir, TagsFile), header, func(w *csv.Writer) error {
+		for _, table := range tables {
+			record := make([]string, 0, len(e.conf.STable.Tags))
+			for _, tag := range e.conf.STable.Tags {
+				record = append(record, stored[table][tag.Field])
+			}
+			if err := w.Write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (e *CsvExporter) exportTable(ctx context.Context, table string) string {
+	start := time.Now()
+	file := filepath.Join(e.dir, DataDir, e.fileName(table)+".csv")
+	columns := e.conf.STable.Columns
+	param := db_table.ReadParam{
+		DBName:    e.conf.DB.Name,
+		TableName: table,
+		Columns:   make([]string, 0, len(columns)),
+		TsColumn:  columns[0].Field,
+		From:      e.from,
+		To:        e.to,
+	}
+	header := make([]string, 0, len(columns))
+	for _, column := range columns {
+		param.Columns = append(param.Columns, column.Field)
+		header = append(header, columnHeader(column))
+	}
+
+	rows := 0
+	err := writeCsv(file, header, func(w *csv.Writer) error {
+		record := make([]string, len(columns))
+		return e.source.ReadTable(ctx, param, func(values []any) error {
+			for i, v := range values {
+				record[i] = value(v, columns[i].Type)
+			}
+			rows++
+			return w.Write(record)
+		})
+	})
+	e.Rows.Add(int64(rows))
+	if err != nil {
+		_ = os.Remove(file)
+		e.Failed.Add(1)
+		return fmt.Sprintf("## export table [%s] to file [%s] error %v", table, file, err)
+	}
+	e.Tables.Add(1)
+	return fmt.Sprintf("## export table [%s] to file [%s] finished. rows-[%d] spend-[%d] ms", table, file, rows, time.Since(start).Milliseconds())
+}
+
+// fileName 子表名去掉 child_table_name_prefix，import 时加上前缀得到相同的子表名
+func (e *CsvExporter) fileName(table string) string {
+	name := strings.TrimPrefix(table, e.conf.STable.ChildTableNamePrefix)
+	if len(name) == 0 {
+		return table
+	}
+	return name
+}
+
+func writeCsv(file string, header []string, write func(w *csv.Writer) error) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	buffer := bufio.NewWriter(f)
+	w := csv.NewWriter(buffer)
+	if err = w.Write(header); err != nil {
+		return err
+	}
+	if err = write(w); err != nil {
+		return err
+	}
+	w.Flush()
+	if err = w.Error(); err != nil {
+		return err
+	}
+	if err = buffer.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// columnHeader source 是 csv 中的列名时用 source，否则用列名
+func columnHeader(column config.Column) string {
+	if token.IsIdentifier(column.Source) {
+		return column.Source
+	}
+	return column.Field
+}
+
+// value 导出的值可以被 import 读回：null 为空，时间戳为 ISO-8601，varbinary 为 0x 开头的十六进制
+func value(v any, columnType string) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case []byte:
+		if common.BaseType(columnType) == common.TypeVarbinary {
+			return "0x" + hex.EncodeToString(v)
+		}
+		return string(v)
+	default:
+		return common.String(v)
+	}
+}
+
+func timeBound(s string, precision string) (*time.Time, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := common.Timestamp(s, precision)
+	if err != nil {
+		return nil, fmt.Errorf("time bound [%s] error %v", s, err)
+	}
+	return &t, nil
+}
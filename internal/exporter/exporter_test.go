@@ -0,0 +1,148 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"testing"
+	"time"
+)
+
+// fakeSource 按子表返回固定的行，记录查询参数
+type fakeSource struct {
+	tables map[string]map[string]string
+	rows   map[string][][]any
+	params []db_table.ReadParam
+}
+
+func (s *fakeSource) ExistingTables(_ context.Context, _ string, _ string) (map[string]map[string]string, error) {
+	return s.tables, nil
+}
+
+func (s *fakeSource) ReadTable(_ context.Context, param db_table.ReadParam, fn func(values []any) error) error {
+	s.params = append(s.params, param)
+	rows, ok := s.rows[param.TableName]
+	if !ok {
+		return fmt.Errorf("table [%s] not exist", param.TableName)
+	}
+	for _, values := range rows {
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestCsvExporter_Export(t *testing.T) {
+	conf := config.Config{
+		DB: config.Database{Name: "stock", Precision: "ms"},
+		STable: config.STable{
+			Name:                 "quote",
+			ChildTableNamePrefix: "t_",
+			Columns: []config.Column{
+				{Field: "ts", Type: "timestamp", Source: "date_parse(date, \"YYYYMMDD\")"},
+				{Field: "price", Type: "double", Source: "price"},
+				{Field: "memo", Type: "nchar(10)", Source: "note"},
+				{Field: "raw", Type: "varbinary(8)", Source: "raw"},
+			},
+			Tags: []config.Column{
+				{Field: "code", Type: "varchar(10)", Source: "code"},
+				{Field: "area", Type: "varchar(10)", Source: "upper(area)"},
+			},
+		},
+	}
+	ts := time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC)
+	tables := map[string]map[string]string{
+		"t_a1": {"code": "a1", "area": "SH"},
+		"t_b2": {"code": "b2"},
+		"c3":   {"code": "c,3", "area": "SZ"},
+	}
+	rows := map[string][][]any{
+		"t_a1": {{ts, 1.5, "x,y", []byte{0x0a, 0xff}}, {ts.Add(time.Second), float64(2), nil, nil}},
+		"t_b2": {{ts, 3.25, "z", nil}},
+	}
+
+	cases := []struct {
+		name   string
+		filter string
+		from   string
+		files  map[string]string
+		tags   string
+		tables int
+		failed int64
+		rows   int64
+	}{
+		{
+			name: "all",
+			files: map[string]string{
+				"a1.csv": "ts,price,note,raw\n2022-11-23T01:46:25.1Z,1.5,\"x,y\",0x0aff\n2022-11-23T01:46:26.1Z,2,,\n",
+				"b2.csv": "ts,price,note,raw\n2022-11-23T01:46:25.1Z,3.25,z,\n",
+			},
+			tags:   "code,area\n\"c,3\",SZ\na1,SH\nb2,\n",
+			tables: 3,
+			failed: 1,
+			rows:   3,
+		},
+		{
+			name:   "tag filter",
+			filter: "area == \"SH\" || code == \"b2\"",
+			from:   "2022-11-23T01:46:26Z",
+			files: map[string]string{
+				"a1.csv": "ts,price,note,raw\n2022-11-23T01:46:25.1Z,1.5,\"x,y\",0x0aff\n2022-11-23T01:46:26.1Z,2,,\n",
+				"b2.csv": "ts,price,note,raw\n2022-11-23T01:46:25.1Z,3.25,z,\n",
+			},
+			tags:   "code,area\na1,SH\nb2,\n",
+			tables: 2,
+			rows:   3,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			source := &fakeSource{tables: tables, rows: rows}
+			e, err := NewCsvExporter(conf, source, Options{Dir: dir, From: c.from, Filter: c.filter})
+			if err != nil {
+				t.Fatal(err)
+			}
+			results, err := e.Export(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != c.tables || e.Failed.Load() != c.failed || e.Rows.Load() != c.rows {
+				t.Errorf("results %v failed %d rows %d", results, e.Failed.Load(), e.Rows.Load())
+			}
+
+			files := make(map[string]string)
+			entries, err := os.ReadDir(filepath.Join(dir, DataDir))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, entry := range entries {
+				b, err := os.ReadFile(filepath.Join(dir, DataDir, entry.Name()))
+				if err != nil {
+					t.Fatal(err)
+				}
+				files[entry.Name()] = string(b)
+			}
+			if !reflect.DeepEqual(files, c.files) {
+				t.Errorf("\n%v\nexpect\n%v", files, c.files)
+			}
+			if b, err := os.ReadFile(filepath.Join(dir, TagsFile)); err != nil || string(b) != c.tags {
+				t.Errorf("tags %q %v, expect %q", b, err, c.tags)
+			}
+			for _, param := range source.params {
+				if param.TsColumn != "ts" || !reflect.DeepEqual(param.Columns, []string{"ts", "price", "memo", "raw"}) {
+					t.Errorf("param %+v", param)
+				}
+				if (len(c.from) > 0) != (param.From != nil) || param.To != nil {
+					t.Errorf("time range %v %v", param.From, param.To)
+				}
+			}
+		})
+	}
+}
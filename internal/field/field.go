@@ -14,30 +14,54 @@ import (
 var emptyExpression = errors.New("field is nil")
 var illegalParams = errors.New("illegal params")
 
-var defaultLocker sync.Mutex
-var DefaultExtractor = NewExtractor(&defaultLocker)
+var DefaultExtractor = NewExtractor()
 
-func NewExtractor(locker sync.Locker) *Extractor {
+func NewExtractor() *Extractor {
 	e := &Extractor{
-		locker: locker,
+		lookups: DefaultLookups,
 	}
 	e.funcMap = map[string]func(args []ast.Expr, data map[string]any) (any, error){
 		"left_pad":                e.leftPad,
 		"right_pad":               e.rightPad,
 		"date_parse":              e.dateParse,
+		"parse_time":              e.parseTime,
+		"parse_iso8601":           e.parseISO8601,
+		"from_unix":               e.fromUnix,
+		"from_excel":              e.fromExcel,
+		"to_tz":                   e.toTZ,
+		"truncate":                e.truncate,
+		"add":                     e.add,
+		"format_time":             e.formatTime,
 		"avoid_datetime_conflict": e.avoidDatetimeConflict,
 		"sub_str":                 e.subStr,
 		"contact":                 e.contact,
+		"concat":                  e.contact,
 		"index_of":                e.indexOf,
+		"upper":                   e.upper,
+		"lower":                   e.lower,
+		"trim":                    e.trim,
+		"replace":                 e.replace,
+		"split":                   e.split,
+		"regex_extract":           e.regexExtract,
+		"regex_replace":           e.regexReplace,
+		"starts_with":             e.startsWith,
+		"ends_with":               e.endsWith,
+		"len":                     e.length,
+		"format":                  e.format,
+		"lookup":                  e.lookup,
+		"col":                     e.col,
+		"seq":                     e.seq,
+		"list":                    e.list,
 	}
 
 	return e
 }
 
 type Extractor struct {
-	exprCache sync.Map // cache expression parsed result, key is expression, value is ast.Expr
-	funcMap   map[string]func(args []ast.Expr, data map[string]any) (any, error)
-	locker    sync.Locker
+	exprCache  sync.Map // cache expression parsed result, key is expression, value is ast.Expr
+	regexCache sync.Map // cache compiled regex, key is pattern, value is *regexp.Regexp
+	funcMap    map[string]func(args []ast.Expr, data map[string]any) (any, error)
+	lookups    *Lookups
 }
 
 func (e *Extractor) Extract(expression string, data map[string]any) (any, error) {
@@ -138,6 +162,26 @@ func (e *Extractor) evalForBinaryExpr(expr *ast.BinaryExpr, data map[string]any)
 		}
 		return evalForNum[float64](xFloat, yFloat, expr.Op)
 	case string:
+		// csv 中的值都是字符串，和数字比较或运算时按数字处理，+ 仍为字符串拼接
+		if expr.Op != token.ADD {
+			switch y.(type) {
+			case int, int32, int64:
+				xInt, err := common.Int64(x)
+				if err != nil {
+					return nil, err
+				}
+				yInt, _ := common.Int64(y)
+				return evalForNum[int64](xInt, yInt, expr.Op)
+			case float32, float64:
+				xFloat, err := common.Float64(x)
+				if err != nil {
+					return nil, err
+				}
+				yFloat, _ := common.Float64(y)
+				return evalForNum[float64](xFloat, yFloat, expr.Op)
+			}
+		}
+
 		xString := common.String(x)
 		yString := common.String(y)
 
@@ -182,6 +226,18 @@ func (e *Extractor) evalForFunc(funcName string, args []ast.Expr, data map[strin
 	return handler(args, data)
 }
 
+func (e *Extractor) evalArgs(args []ast.Expr, data map[string]any) ([]any, error) {
+	values := make([]any, 0, len(args))
+	for _, arg := range args {
+		value, err := e.eval(arg, data)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
 func evalForNum[T int | int32 | int64 | float32 | float64](x, y T, op token.Token) (any, error) {
 	switch op {
 	case token.EQL:
@@ -223,6 +279,14 @@ func (e *Extractor) evalForUnaryExpr(expr *ast.UnaryExpr, data map[string]any) (
 	if b, ok := x.(bool); ok && expr.Op == token.NOT {
 		return !b, nil
 	}
+	if expr.Op == token.SUB {
+		switch x := x.(type) {
+		case int64:
+			return -x, nil
+		case float64:
+			return -x, nil
+		}
+	}
 	return nil, fmt.Errorf("unknown unary field [%v]", expr)
 }
 
@@ -57,3 +57,49 @@ func BenchmarkExtract(b *testing.B) {
 		_, _ = DefaultExtractor.Extract("date_parse(date + left_pad(time, \"0\", 9), \"YYYYMMDDHHmmssSSS\")", map[string]any{"date": "20221123", "time": "94625100"})
 	}
 }
+
+func TestExtract_StringAndNumber(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		data       map[string]any
+		expect     any
+	}{
+		{
+			name:       "1",
+			expression: "trade_volume > 0 && function_code != 67",
+			data:       map[string]any{"trade_volume": "100", "function_code": "70"},
+			expect:     true,
+		},
+		{
+			name:       "2",
+			expression: "trade_volume > 0 && function_code != 67",
+			data:       map[string]any{"trade_volume": "100", "function_code": "67"},
+			expect:     false,
+		},
+		{
+			name:       "3",
+			expression: "price * 1.5",
+			data:       map[string]any{"price": "2"},
+			expect:     float64(3),
+		},
+		{
+			name:       "4",
+			expression: "code + 1",
+			data:       map[string]any{"code": "100"},
+			expect:     "1001",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := DefaultExtractor.Extract(c.expression, c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("## extract fail. expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
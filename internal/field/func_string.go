@@ -0,0 +1,302 @@
+package field
+
+import (
+	"fmt"
+	"go/ast"
+	"regexp"
+	"strings"
+	"taos_importer/internal/common"
+	"unicode/utf8"
+)
+
+// 字符串函数，长度和下标均按 rune 计算，nchar 中的中文不会被截断
+
+func (e *Extractor) leftPad(args []ast.Expr, data map[string]any) (any, error) {
+	str, padStr, toLength, err := e.padParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := padding(str, padStr, toLength)
+	return pad + str, nil
+}
+
+func (e *Extractor) rightPad(args []ast.Expr, data map[string]any) (any, error) {
+	str, padStr, toLength, err := e.padParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	pad := padding(str, padStr, toLength)
+	return str + pad, nil
+}
+
+func (e *Extractor) subStr(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	str := []rune(common.String(values[0]))
+	start, err := common.Int(values[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := common.Int(values[2])
+	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 || start > end || start > len(str) || end > len(str) {
+		return nil, illegalParams
+	}
+
+	return string(str[start:end]), nil
+}
+
+func (e *Extractor) contact(args []ast.Expr, data map[string]any) (any, error) {
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	ss := make([]string, 0, len(values))
+	for _, v := range values {
+		ss = append(ss, common.String(v))
+	}
+
+	return strings.Join(ss, ""), nil
+}
+
+func (e *Extractor) indexOf(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	str := common.String(values[0])
+	index := strings.Index(str, common.String(values[1]))
+	if index <= 0 {
+		return index, nil
+	}
+
+	return utf8.RuneCountInString(str[:index]), nil
+}
+
+func (e *Extractor) upper(args []ast.Expr, data map[string]any) (any, error) {
+	str, err := e.strParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToUpper(str), nil
+}
+
+func (e *Extractor) lower(args []ast.Expr, data map[string]any) (any, error) {
+	str, err := e.strParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+	return strings.ToLower(str), nil
+}
+
+// trim(x) 去除首尾空白，trim(x, cutset) 去除首尾 cutset 中的字符
+func (e *Extractor) trim(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	str := common.String(values[0])
+	if len(values) == 1 {
+		return strings.TrimSpace(str), nil
+	}
+	return strings.Trim(str, common.String(values[1])), nil
+}
+
+func (e *Extractor) replace(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.ReplaceAll(common.String(values[0]), common.String(values[1]), common.String(values[2])), nil
+}
+
+// split(x, sep, i) 返回按 sep 切分后的第 i 段，i 为负数时从末尾开始计数，越界返回 nil
+func (e *Extractor) split(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := strings.Split(common.String(values[0]), common.String(values[1]))
+	i, err := common.Int(values[2])
+	if err != nil {
+		return nil, err
+	}
+	if i < 0 {
+		i = len(parts) + i
+	}
+	if i < 0 || i >= len(parts) {
+		return nil, nil
+	}
+
+	return parts[i], nil
+}
+
+// regex_extract(x, pattern) 返回第一个匹配，regex_extract(x, pattern, group) 返回第一个匹配的第 group 个分组，无匹配返回 nil
+func (e *Extractor) regexExtract(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := e.regex(common.String(values[1]))
+	if err != nil {
+		return nil, err
+	}
+	group := 0
+	if len(values) == 3 {
+		if group, err = common.Int(values[2]); err != nil {
+			return nil, err
+		}
+	}
+
+	match := re.FindStringSubmatch(common.String(values[0]))
+	if group < 0 || group >= len(match) {
+		return nil, nil
+	}
+
+	return match[group], nil
+}
+
+// regex_replace(x, pattern, repl)，repl 中可以使用 $1 引用分组
+func (e *Extractor) regexReplace(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	re, err := e.regex(common.String(values[1]))
+	if err != nil {
+		return nil, err
+	}
+
+	return re.ReplaceAllString(common.String(values[0]), common.String(values[2])), nil
+}
+
+func (e *Extractor) startsWith(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasPrefix(common.String(values[0]), common.String(values[1])), nil
+}
+
+func (e *Extractor) endsWith(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return strings.HasSuffix(common.String(values[0]), common.String(values[1])), nil
+}
+
+func (e *Extractor) length(args []ast.Expr, data map[string]any) (any, error) {
+	str, err := e.strParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+	return int64(utf8.RuneCountInString(str)), nil
+}
+
+// format("%s-%d", ...) 同 fmt.Sprintf
+func (e *Extractor) format(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) == 0 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return fmt.Sprintf(common.String(values[0]), values[1:]...), nil
+}
+
+func (e *Extractor) padParam(args []ast.Expr, data map[string]any) (string, string, int, error) {
+	if len(args) != 3 {
+		return "", "", 0, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	str := common.String(values[0])
+	padStr := common.String(values[1])
+	toLength, err := common.Int(values[2])
+	return str, padStr, toLength, err
+}
+
+func (e *Extractor) strParam(args []ast.Expr, data map[string]any) (string, error) {
+	if len(args) != 1 {
+		return "", illegalParams
+	}
+	value, err := e.eval(args[0], data)
+	if err != nil {
+		return "", err
+	}
+	return common.String(value), nil
+}
+
+func (e *Extractor) regex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := e.regexCache.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	e.regexCache.Store(pattern, re)
+	return re, nil
+}
+
+// padding 返回把 str 补齐到 toLength 个字符所需的填充串
+func padding(str, padStr string, toLength int) string {
+	needPad := toLength - utf8.RuneCountInString(str)
+	if needPad <= 0 || len(padStr) == 0 {
+		return ""
+	}
+
+	pad := []rune(strings.Repeat(padStr, needPad))
+	return string(pad[:needPad])
+}
@@ -0,0 +1,185 @@
+package field
+
+import (
+	"testing"
+)
+
+func TestStringFunc(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		data       map[string]any
+		expect     any
+	}{
+		{
+			name:       "left_pad",
+			expression: "left_pad(name, \"0\", 6)",
+			data:       map[string]any{"name": "浦发银行"},
+			expect:     "00浦发银行",
+		},
+		{
+			name:       "right_pad",
+			expression: "right_pad(name, \"ab\", 5)",
+			data:       map[string]any{"name": "中国"},
+			expect:     "中国aba",
+		},
+		{
+			name:       "sub_str",
+			expression: "sub_str(name, 2, 4)",
+			data:       map[string]any{"name": "浦发银行股份"},
+			expect:     "银行",
+		},
+		{
+			name:       "index_of",
+			expression: "index_of(name, \"银行\")",
+			data:       map[string]any{"name": "浦发银行"},
+			expect:     2,
+		},
+		{
+			name:       "index_of not found",
+			expression: "index_of(name, \"x\")",
+			data:       map[string]any{"name": "浦发银行"},
+			expect:     -1,
+		},
+		{
+			name:       "concat",
+			expression: "concat(\"t_\", code, \"_\", 1)",
+			data:       map[string]any{"code": "600000"},
+			expect:     "t_600000_1",
+		},
+		{
+			name:       "upper",
+			expression: "upper(code)",
+			data:       map[string]any{"code": "600000.sh"},
+			expect:     "600000.SH",
+		},
+		{
+			name:       "lower",
+			expression: "lower(code)",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "600000.sh",
+		},
+		{
+			name:       "trim",
+			expression: "trim(name)",
+			data:       map[string]any{"name": "  浦发银行\t"},
+			expect:     "浦发银行",
+		},
+		{
+			name:       "trim cutset",
+			expression: "trim(name, \"*\")",
+			data:       map[string]any{"name": "**ST**"},
+			expect:     "ST",
+		},
+		{
+			name:       "replace",
+			expression: "replace(code, \".\", \"_\")",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "600000_SH",
+		},
+		{
+			name:       "split",
+			expression: "split(code, \".\", 1)",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "SH",
+		},
+		{
+			name:       "split negative",
+			expression: "split(code, \".\", -2)",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "600000",
+		},
+		{
+			name:       "split out of range",
+			expression: "split(code, \".\", 2)",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     nil,
+		},
+		{
+			name:       "regex_extract",
+			expression: "regex_extract(code, \"^(\\\\d+)\\\\.(\\\\w+)$\", 2)",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "SH",
+		},
+		{
+			name:       "regex_extract no match",
+			expression: "regex_extract(code, \"^\\\\d+$\")",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     nil,
+		},
+		{
+			name:       "regex_replace",
+			expression: "regex_replace(code, \"^(\\\\d+)\\\\.(\\\\w+)$\", \"$2$1\")",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "SH600000",
+		},
+		{
+			name:       "starts_with",
+			expression: "starts_with(code, \"60\")",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     true,
+		},
+		{
+			name:       "ends_with",
+			expression: "ends_with(code, \".SZ\")",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     false,
+		},
+		{
+			name:       "len",
+			expression: "len(name)",
+			data:       map[string]any{"name": "浦发银行"},
+			expect:     int64(4),
+		},
+		{
+			name:       "format",
+			expression: "format(\"%s-%d\", code, 12)",
+			data:       map[string]any{"code": "600000"},
+			expect:     "600000-12",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := DefaultExtractor.Extract(c.expression, c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("## extract fail. expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestStringFuncError(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		data       map[string]any
+	}{
+		{
+			name:       "sub_str out of range",
+			expression: "sub_str(name, 2, 5)",
+			data:       map[string]any{"name": "浦发银行"},
+		},
+		{
+			name:       "regex_extract bad pattern",
+			expression: "regex_extract(name, \"(\")",
+			data:       map[string]any{"name": "浦发银行"},
+		},
+		{
+			name:       "upper args",
+			expression: "upper(name, name)",
+			data:       map[string]any{"name": "浦发银行"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := DefaultExtractor.Extract(c.expression, c.data); err == nil {
+				t.Fatalf("## extract [%s] should fail", c.expression)
+			}
+		})
+	}
+}
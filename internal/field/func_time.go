@@ -0,0 +1,254 @@
+package field
+
+import (
+	"go/ast"
+	"strings"
+	"taos_importer/internal/common"
+	"time"
+)
+
+// 时间函数。format/layout 参数既可以是 YYYY MM DD HH mm ss SSS 风格，也可以是 go 的 2006-01-02 风格
+
+// date_parse(x, format) 按 UTC 解析，date_parse(x, format, location) 按指定时区解析
+func (e *Extractor) dateParse(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if len(values) == 3 {
+		if location, err = common.Location(common.String(values[2])); err != nil {
+			return nil, err
+		}
+	}
+
+	date := common.String(values[0])
+	format := common.String(values[1])
+
+	return parseDate(format, date, location)
+}
+
+// parse_time(x, layout1, layout2, ...) 依次尝试每个 layout，返回第一个解析成功的结果
+func (e *Extractor) parseTime(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) < 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	date := common.String(values[0])
+	for _, layout := range values[1:] {
+		var t time.Time
+		if t, err = parseDate(common.String(layout), date, time.UTC); err == nil {
+			return t, nil
+		}
+	}
+	return nil, err
+}
+
+func (e *Extractor) parseISO8601(args []ast.Expr, data map[string]any) (any, error) {
+	str, err := e.strParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+	return common.ParseISO8601(str)
+}
+
+// from_unix(x, unit)，unit 为 s|ms|us|ns，秒允许带小数
+func (e *Extractor) fromUnix(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	unit := common.String(values[1])
+	if unit == "s" {
+		f, err := common.Float64(values[0])
+		if err != nil {
+			return nil, err
+		}
+		return time.Unix(0, int64(f*float64(time.Second))), nil
+	}
+
+	v, err := common.Int64(values[0])
+	if err != nil {
+		return nil, err
+	}
+	return common.FromUnix(v, unit)
+}
+
+// from_excel(x) 或 from_excel(x, location)，x 为 excel 序列日期
+func (e *Extractor) fromExcel(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	location := time.UTC
+	if len(values) == 2 {
+		if location, err = common.Location(common.String(values[1])); err != nil {
+			return nil, err
+		}
+	}
+	serial, err := common.Float64(values[0])
+	if err != nil {
+		return nil, err
+	}
+
+	return common.FromExcel(serial, location), nil
+}
+
+// to_tz(ts, "Asia/Shanghai") 转换到指定时区，时间点不变
+func (e *Extractor) toTZ(args []ast.Expr, data map[string]any) (any, error) {
+	ts, values, err := e.timeParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := common.Location(common.String(values[0]))
+	if err != nil {
+		return nil, err
+	}
+	return ts.In(location), nil
+}
+
+// truncate(ts, "1m")，按天的周期在 ts 所在时区对齐
+func (e *Extractor) truncate(args []ast.Expr, data map[string]any) (any, error) {
+	ts, values, err := e.timeParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := common.ParseDuration(common.String(values[0]))
+	if err != nil {
+		return nil, err
+	}
+	return common.TruncateTime(ts, d), nil
+}
+
+// add(ts, "8h")，支持负数 "-8h"
+func (e *Extractor) add(args []ast.Expr, data map[string]any) (any, error) {
+	ts, values, err := e.timeParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := common.ParseDuration(common.String(values[0]))
+	if err != nil {
+		return nil, err
+	}
+	return ts.Add(d), nil
+}
+
+func (e *Extractor) formatTime(args []ast.Expr, data map[string]any) (any, error) {
+	ts, values, err := e.timeParam(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatDate(common.String(values[0]), ts), nil
+}
+
+// timeParam 解析形如 f(ts, arg) 的参数，整数 ts 按毫秒时间戳处理
+func (e *Extractor) timeParam(args []ast.Expr, data map[string]any) (time.Time, []any, error) {
+	if len(args) != 2 {
+		return time.Time{}, nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return time.Time{}, nil, err
+	}
+
+	ts, err := common.Timestamp(values[0], common.PrecisionMilliSecond)
+	return ts, values[1:], err
+}
+
+func parseDate(format, date string, location *time.Location) (time.Time, error) {
+	format = goLayout(format)
+	// 纳秒
+	format, date = nsFormatStyle(format, date, "SSSSSSSSS", "000000000")
+
+	// 微秒
+	format, date = nsFormatStyle(format, date, "SSSSSS", "000000")
+
+	// 毫秒
+	format, date = nsFormatStyle(format, date, "SSS", "000")
+
+	return time.ParseInLocation(format, date, location)
+}
+
+func formatDate(format string, ts time.Time) string {
+	format = goLayout(format)
+
+	for _, s := range []string{"SSSSSSSSS", "SSSSSS", "SSS"} {
+		index := strings.Index(format, s)
+		if index < 0 {
+			continue
+		}
+
+		format = strings.ReplaceAll(format, s, strings.Repeat("0", len(s)))
+		if index > 0 && format[index-1] == '.' {
+			break
+		}
+		// go 只能格式化带小数点的秒，格式化后再去掉补的小数点
+		format = format[:index] + "." + format[index:]
+		str := ts.Format(format)
+		return str[:index] + str[index+1:]
+	}
+
+	return ts.Format(format)
+}
+
+func goLayout(format string) string {
+	if strings.Contains(format, "YYYY") {
+		format = strings.ReplaceAll(format, "YYYY", "2006")
+	}
+	if strings.Contains(format, "yyyy") {
+		format = strings.ReplaceAll(format, "yyyy", "2006")
+	}
+	if strings.Contains(format, "MM") {
+		format = strings.ReplaceAll(format, "MM", "01")
+	}
+	if strings.Contains(format, "DD") {
+		format = strings.ReplaceAll(format, "DD", "02")
+	}
+	if strings.Contains(format, "dd") {
+		format = strings.ReplaceAll(format, "dd", "02")
+	}
+	if strings.Contains(format, "HH") {
+		format = strings.ReplaceAll(format, "HH", "15")
+	}
+	if strings.Contains(format, "hh") {
+		format = strings.ReplaceAll(format, "hh", "15")
+	}
+	if strings.Contains(format, "mm") {
+		format = strings.ReplaceAll(format, "mm", "04")
+	}
+	if strings.Contains(format, "ss") {
+		format = strings.ReplaceAll(format, "ss", "05")
+	}
+	return format
+}
+
+func nsFormatStyle(format, date string, old, new string) (string, string) {
+	if index := strings.Index(format, old); index > 0 {
+		format = strings.ReplaceAll(format, old, new)
+		if format[index-1:index] != "." && len(date) >= index {
+			format = format[:index] + "." + format[index:]
+			date = date[:index] + "." + date[index:]
+		}
+	}
+	return format, date
+}
@@ -0,0 +1,142 @@
+package field
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTimeFunc(t *testing.T) {
+	shanghai, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name       string
+		expression string
+		data       map[string]any
+		expect     time.Time
+	}{
+		{
+			name:       "date_parse location",
+			expression: "date_parse(date + left_pad(time, \"0\", 9), \"YYYYMMDDHHmmssSSS\", \"Asia/Shanghai\")",
+			data:       map[string]any{"date": "20221123", "time": "94625100"},
+			expect:     time.Date(2022, 11, 23, 9, 46, 25, 100000000, shanghai),
+		},
+		{
+			name:       "from_unix s",
+			expression: "from_unix(ts, \"s\")",
+			data:       map[string]any{"ts": "1669167985.5"},
+			expect:     time.Date(2022, 11, 23, 1, 46, 25, 500000000, time.UTC),
+		},
+		{
+			name:       "from_unix ms",
+			expression: "from_unix(ts, \"ms\")",
+			data:       map[string]any{"ts": "1669167985100"},
+			expect:     time.Date(2022, 11, 23, 1, 46, 25, 100000000, time.UTC),
+		},
+		{
+			name:       "from_unix ns",
+			expression: "from_unix(ts, \"ns\")",
+			data:       map[string]any{"ts": "1669167985000000001"},
+			expect:     time.Date(2022, 11, 23, 1, 46, 25, 1, time.UTC),
+		},
+		{
+			name:       "from_excel",
+			expression: "from_excel(d)",
+			data:       map[string]any{"d": "44888.5"},
+			expect:     time.Date(2022, 11, 23, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "parse_iso8601",
+			expression: "parse_iso8601(ts)",
+			data:       map[string]any{"ts": "2022-11-23T09:46:25.1+08:00"},
+			expect:     time.Date(2022, 11, 23, 9, 46, 25, 100000000, shanghai),
+		},
+		{
+			name:       "parse_iso8601 without zone",
+			expression: "parse_iso8601(ts)",
+			data:       map[string]any{"ts": "2022-11-23 09:46:25"},
+			expect:     time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC),
+		},
+		{
+			name:       "parse_time fallback",
+			expression: "parse_time(ts, \"YYYY-MM-DD HH:mm:ss\", \"YYYY/MM/DD\", \"2006.01.02\")",
+			data:       map[string]any{"ts": "2022/11/23"},
+			expect:     time.Date(2022, 11, 23, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:       "to_tz",
+			expression: "to_tz(from_unix(ts, \"s\"), \"Asia/Shanghai\")",
+			data:       map[string]any{"ts": "1669167985"},
+			expect:     time.Date(2022, 11, 23, 9, 46, 25, 0, shanghai),
+		},
+		{
+			name:       "truncate minute",
+			expression: "truncate(parse_iso8601(ts), \"1m\")",
+			data:       map[string]any{"ts": "2022-11-23T09:46:25Z"},
+			expect:     time.Date(2022, 11, 23, 9, 46, 0, 0, time.UTC),
+		},
+		{
+			name:       "truncate day in location",
+			expression: "truncate(to_tz(parse_iso8601(ts), \"Asia/Shanghai\"), \"1d\")",
+			data:       map[string]any{"ts": "2022-11-22T20:00:00Z"},
+			expect:     time.Date(2022, 11, 23, 0, 0, 0, 0, shanghai),
+		},
+		{
+			name:       "add",
+			expression: "add(parse_iso8601(ts), \"-8h\")",
+			data:       map[string]any{"ts": "2022-11-23T09:46:25Z"},
+			expect:     time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := DefaultExtractor.Extract(c.expression, c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts, ok := res.(time.Time); !ok || !ts.Equal(c.expect) {
+				t.Fatalf("## extract fail. expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	cases := []struct {
+		name       string
+		expression string
+		expect     string
+	}{
+		{
+			name:       "1",
+			expression: "format_time(ts, \"YYYYMMDDHHmmssSSS\")",
+			expect:     "20221123094625100",
+		},
+		{
+			name:       "2",
+			expression: "format_time(ts, \"YYYY-MM-DD HH:mm:ss.SSSSSS\")",
+			expect:     "2022-11-23 09:46:25.100000",
+		},
+		{
+			name:       "3",
+			expression: "format_time(ts, \"2006-01-02\")",
+			expect:     "2022-11-23",
+		},
+	}
+
+	data := map[string]any{"ts": time.Date(2022, 11, 23, 9, 46, 25, 100000000, time.UTC)}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := DefaultExtractor.Extract(c.expression, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("## extract fail. expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+}
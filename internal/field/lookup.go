@@ -0,0 +1,242 @@
+package field
+
+import (
+	"bufio"
+	"encoding/csv"
+	"fmt"
+	"go/ast"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"taos_importer/internal/common"
+)
+
+const (
+	LookupFormatCsv = "csv" // 带表头的 csv，由 key/value 指定列
+	LookupFormatKV  = "kv"  // 每行一个 key<separator>value，# 开头为注释
+)
+
+var DefaultLookups = NewLookups()
+
+// LookupSource 字典的来源
+type LookupSource struct {
+	Name      string
+	File      string
+	Format    string // csv | kv, 为空时按文件后缀判断
+	Key       string // csv 的 key 列
+	Value     string // csv 的 value 列
+	Separator string // kv 的分隔符, 默认 =
+}
+
+// Lookups 保存所有字典，加载和重新加载时整表替换，读取方不会看到加载了一半的字典
+type Lookups struct {
+	mu      sync.RWMutex
+	sources map[string]LookupSource
+	tables  map[string]map[string]string
+	missing map[string]map[string]int64 // name -> key -> 未命中次数
+}
+
+func NewLookups() *Lookups {
+	return &Lookups{
+		sources: make(map[string]LookupSource),
+		tables:  make(map[string]map[string]string),
+		missing: make(map[string]map[string]int64),
+	}
+}
+
+// Load 加载（或重新加载）一个字典
+func (l *Lookups) Load(source LookupSource) error {
+	if len(source.Name) == 0 {
+		return fmt.Errorf("lookup name is null")
+	}
+
+	table, err := readLookup(source)
+	if err != nil {
+		return fmt.Errorf("load lookup-[%s] from [%s] error %v", source.Name, source.File, err)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sources[source.Name] = source
+	l.tables[source.Name] = table
+	return nil
+}
+
+// Reload 按原来的来源重新加载所有字典，加载失败的字典保留旧内容，返回所有失败的原因
+func (l *Lookups) Reload() error {
+	l.mu.RLock()
+	sources := make([]LookupSource, 0, len(l.sources))
+	for _, source := range l.sources {
+		sources = append(sources, source)
+	}
+	l.mu.RUnlock()
+	sort.Slice(sources, func(i, j int) bool { return sources[i].Name < sources[j].Name })
+
+	var errs []string
+	for _, source := range sources {
+		if err := l.Load(source); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// Get 查询字典，字典不存在时返回 error，key 不存在时记录一次未命中
+func (l *Lookups) Get(name string, key string) (string, bool, error) {
+	l.mu.RLock()
+	table, ok := l.tables[name]
+	if !ok {
+		l.mu.RUnlock()
+		return "", false, fmt.Errorf("unknown lookup [%s]", name)
+	}
+	value, ok := table[key]
+	l.mu.RUnlock()
+	if ok {
+		return value, true, nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.missing[name] == nil {
+		l.missing[name] = make(map[string]int64)
+	}
+	l.missing[name][key]++
+	return "", false, nil
+}
+
+// MissingReport 返回未命中的 key 及次数，每行一个，按字典名和 key 排序
+func (l *Lookups) MissingReport() []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	report := make([]string, 0)
+	for name, keys := range l.missing {
+		for key, count := range keys {
+			report = append(report, fmt.Sprintf("## lookup [%s] missing key-[%s] count-[%d]", name, key, count))
+		}
+	}
+	sort.Strings(report)
+	return report
+}
+
+// lookup(name, key) 或 lookup(name, key, default)，key 不存在时返回 default，未指定 default 则返回 nil
+func (e *Extractor) lookup(args []ast.Expr, data map[string]any) (any, error) {
+	if len(args) != 2 && len(args) != 3 {
+		return nil, illegalParams
+	}
+	values, err := e.evalArgs(args, data)
+	if err != nil {
+		return nil, err
+	}
+
+	value, ok, err := e.lookups.Get(common.String(values[0]), common.String(values[1]))
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return value, nil
+	}
+	if len(values) == 3 {
+		return values[2], nil
+	}
+	return nil, nil
+}
+
+func readLookup(source LookupSource) (map[string]string, error) {
+	format := source.Format
+	if len(format) == 0 {
+		format = LookupFormatKV
+		if strings.EqualFold(path.Ext(source.File), ".csv") {
+			format = LookupFormatCsv
+		}
+	}
+
+	switch format {
+	case LookupFormatCsv:
+		return readCsvLookup(source)
+	case LookupFormatKV:
+		return readKVLookup(source)
+	default:
+		return nil, fmt.Errorf("unknown lookup format [%s]", format)
+	}
+}
+
+func readCsvLookup(source LookupSource) (map[string]string, error) {
+	if len(source.Key) == 0 || len(source.Value) == 0 {
+		return nil, fmt.Errorf("key and value column are required for csv lookup")
+	}
+
+	// 不用 common.ReadCsv，读取中途出错时要返回 error，不能用读了一半的字典替换旧的
+	f, err := os.Open(source.File)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	reader := csv.NewReader(f)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("read header error %v", err)
+	}
+	keyIndex, valueIndex := -1, -1
+	for i, h := range header {
+		if h == source.Key {
+			keyIndex = i
+		}
+		if h == source.Value {
+			valueIndex = i
+		}
+	}
+	if keyIndex < 0 {
+		return nil, fmt.Errorf("key column-[%s] not found", source.Key)
+	}
+	if valueIndex < 0 {
+		return nil, fmt.Errorf("value column-[%s] not found", source.Value)
+	}
+
+	table := make(map[string]string)
+	for {
+		records, err := reader.Read()
+		if err == io.EOF {
+			return table, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		table[records[keyIndex]] = records[valueIndex]
+	}
+}
+
+func readKVLookup(source LookupSource) (map[string]string, error) {
+	f, err := os.Open(source.File)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	separator := source.Separator
+	if len(separator) == 0 {
+		separator = "="
+	}
+
+	table := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for num := 1; scanner.Scan(); num++ {
+		text := strings.TrimSpace(scanner.Text())
+		if len(text) == 0 || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(text, separator)
+		if !ok {
+			return nil, fmt.Errorf("line-[%d] separator [%s] not found", num, separator)
+		}
+		table[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return table, scanner.Err()
+}
@@ -0,0 +1,163 @@
+package field
+
+import (
+	"os"
+	"path"
+	"testing"
+)
+
+func TestLookup(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := path.Join(dir, "industry.csv")
+	kvFile := path.Join(dir, "exchange.txt")
+	if err := os.WriteFile(csvFile, []byte("code,name\n801010,农林牧渔\n801780,银行\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(kvFile, []byte("# exchange\nSH = 上海证券交易所\nSZ=深圳证券交易所\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	lookups := NewLookups()
+	if err := lookups.Load(LookupSource{Name: "industry", File: csvFile, Key: "code", Value: "name"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookups.Load(LookupSource{Name: "exchange", File: kvFile}); err != nil {
+		t.Fatal(err)
+	}
+	e := NewExtractor()
+	e.lookups = lookups
+
+	cases := []struct {
+		name       string
+		expression string
+		data       map[string]any
+		expect     any
+	}{
+		{
+			name:       "csv",
+			expression: "lookup(\"industry\", code, \"unknown\")",
+			data:       map[string]any{"code": "801780"},
+			expect:     "银行",
+		},
+		{
+			name:       "kv",
+			expression: "lookup(\"exchange\", split(code, \".\", 1))",
+			data:       map[string]any{"code": "600000.SH"},
+			expect:     "上海证券交易所",
+		},
+		{
+			name:       "default",
+			expression: "lookup(\"industry\", code, \"unknown\")",
+			data:       map[string]any{"code": "999999"},
+			expect:     "unknown",
+		},
+		{
+			name:       "nil",
+			expression: "lookup(\"exchange\", code)",
+			data:       map[string]any{"code": "BJ"},
+			expect:     nil,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := e.Extract(c.expression, c.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != c.expect {
+				t.Fatalf("## lookup fail. expect-[%v] but got-[%v]", c.expect, res)
+			}
+		})
+	}
+
+	report := lookups.MissingReport()
+	if len(report) != 2 {
+		t.Fatalf("expect 2 missing keys, but got %v", report)
+	}
+	if _, err := e.Extract("lookup(\"sector\", code)", map[string]any{"code": "1"}); err == nil {
+		t.Fatal("unknown lookup should fail")
+	}
+}
+
+func TestLookup_Reload(t *testing.T) {
+	file := path.Join(t.TempDir(), "exchange.txt")
+	if err := os.WriteFile(file, []byte("SH=上海\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	lookups := NewLookups()
+	if err := lookups.Load(LookupSource{Name: "exchange", File: file}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(file, []byte("SH=上海证券交易所\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookups.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if v, _, _ := lookups.Get("exchange", "SH"); v != "上海证券交易所" {
+		t.Fatalf("reload fail, got-[%s]", v)
+	}
+
+	// 加载失败时保留旧内容
+	if err := os.WriteFile(file, []byte("SH\n"), 0666); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookups.Reload(); err == nil {
+		t.Fatal("reload bad file should fail")
+	}
+	if v, _, _ := lookups.Get("exchange", "SH"); v != "上海证券交易所" {
+		t.Fatalf("failed reload should keep old table, got-[%s]", v)
+	}
+}
+
+func TestLookup_ReloadCsv(t *testing.T) {
+	dir := t.TempDir()
+	csvFile := path.Join(dir, "industry.csv")
+	kvFile := path.Join(dir, "exchange.txt")
+	write := func(file string, data string) {
+		if err := os.WriteFile(file, []byte(data), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(csvFile, "code,name\n801780,银行\n")
+	write(kvFile, "SH=上海\n")
+	lookups := NewLookups()
+	if err := lookups.Load(LookupSource{Name: "industry", File: csvFile, Key: "code", Value: "name"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := lookups.Load(LookupSource{Name: "exchange", File: kvFile}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		csv      string
+		industry string // 重新加载后 801780 的值
+	}{
+		{name: "error in middle", csv: "code,name\n801780,银行业\n801010\n", industry: "银行"},
+		{name: "value column not found", csv: "code,title\n801780,银行业\n", industry: "银行"},
+		{name: "ok", csv: "code,name\n801780,银行业\n", industry: "银行业"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			write(csvFile, c.csv)
+			write(kvFile, "SH=上海证券交易所\n")
+			err := lookups.Reload()
+			if (err != nil) != (c.industry == "银行") {
+				t.Fatalf("reload error %v", err)
+			}
+			// 一个字典失败不影响其他字典重新加载
+			if v, _, _ := lookups.Get("exchange", "SH"); v != "上海证券交易所" {
+				t.Fatalf("other lookup is not reloaded, got-[%s]", v)
+			}
+			if v, _, _ := lookups.Get("industry", "801780"); v != c.industry {
+				t.Fatalf("expect-[%s] but got-[%s]", c.industry, v)
+			}
+			write(kvFile, "SH=上海\n")
+			_ = lookups.Load(LookupSource{Name: "exchange", File: kvFile})
+		})
+	}
+}
@@ -0,0 +1,288 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"time"
+)
+
+const (
+	AggregateAlongside = "alongside" // 同时写入原始数据和聚合结果
+	AggregateInstead   = "instead"   // 只写入聚合结果
+
+	AggFirst = "first"
+	AggLast  = "last"
+	AggMin   = "min"
+	AggMax   = "max"
+	AggSum   = "sum"
+	AggCount = "count"
+	AggAvg   = "avg"
+)
+
+// AggregateTableSuffix 聚合子表名的后缀，子表名为原始子表名加后缀
+func AggregateTableSuffix(conf config.Aggregate) string {
+	if len(conf.TableSuffix) > 0 {
+		return conf.TableSuffix
+	}
+	return "_" + conf.Window
+}
+
+// aggregator 按子表和时间窗口聚合行。
+// 每个子表的行先经过最多 buffer 行的排序缓存，新窗口出现时输出并释放前面的窗口，每个窗口只输出一次。
+// 乱序超出缓存、所在窗口已经输出的迟到行不参与聚合，计入 late。
+type aggregator struct {
+	window  time.Duration
+	suffix  string
+	tsIndex int
+	columns []aggColumn
+	buffer  config.Sort
+	sorters map[string]*sorter        // table -> 排序缓存
+	bars    map[string]map[int64]*bar // table -> 窗口开始时间 -> 未输出的 bar
+	current map[string]int64          // table -> 当前窗口，更早的窗口已经输出
+	late    int64
+}
+
+type aggColumn struct {
+	fn    string
+	index int // 原始行中的下标，count 可以为 -1，即 count(*)
+}
+
+type bar struct {
+	table  string
+	start  time.Time
+	states []aggState
+}
+
+type aggState struct {
+	first, last     any
+	firstTs, lastTs time.Time
+	min, max, sum   float64
+	count, numbers  int64
+}
+
+func newAggregator(conf config.Aggregate, columns []config.Column) (*aggregator, error) {
+	if len(conf.STable) == 0 {
+		return nil, nil
+	}
+	if conf.Mode != "" && conf.Mode != AggregateAlongside && conf.Mode != AggregateInstead {
+		return nil, fmt.Errorf("unknown aggregate mode [%s]", conf.Mode)
+	}
+
+	window, err := common.ParseDuration(conf.Window)
+	if err != nil || window <= 0 {
+		return nil, fmt.Errorf("aggregate window [%s] error %v", conf.Window, err)
+	}
+	a := &aggregator{
+		window:  window,
+		suffix:  AggregateTableSuffix(conf),
+		tsIndex: timestampIndex(columns),
+		buffer:  config.Sort{Scope: SortWindow, Size: conf.Buffer},
+		sorters: make(map[string]*sorter),
+		bars:    make(map[string]map[int64]*bar),
+		current: make(map[string]int64),
+	}
+	if a.tsIndex < 0 {
+		return nil, fmt.Errorf("aggregate needs a timestamp column")
+	}
+
+	for _, column := range conf.Columns {
+		c := aggColumn{fn: column.Func, index: -1}
+		switch column.Func {
+		case AggFirst, AggLast, AggMin, AggMax, AggSum, AggCount, AggAvg:
+		default:
+			return nil, fmt.Errorf("aggregate column-[%s] unknown func [%s]", column.Field, column.Func)
+		}
+		for i, raw := range columns {
+			if raw.Field == column.Source {
+				c.index = i
+			}
+		}
+		if c.index < 0 && column.Func != AggCount {
+			return nil, fmt.Errorf("aggregate column-[%s] source column-[%s] not found", column.Field, column.Source)
+		}
+		a.columns = append(a.columns, c)
+	}
+	return a, nil
+}
+
+// AggregateColumns 聚合结果的列，第一列为窗口开始时间
+func AggregateColumns(conf config.Aggregate) []config.Column {
+	columns := []config.Column{{Field: "ts", Type: common.TypeTimeStamp}}
+	for _, column := range conf.Columns {
+		columns = append(columns, config.Column{Field: column.Field, Type: column.Type})
+	}
+	return columns
+}
+
+// add 按顺序加入一批行，返回已经结束的窗口，table 为 row.table 为空时的子表名
+func (a *aggregator) add(rows []row, table string) []row {
+	var closed []row
+
+	tables, groups := groupByTable(rows)
+	for _, t := range tables {
+		name := t
+		if len(name) == 0 {
+			name = table
+		}
+		s, ok := a.sorters[name]
+		if !ok {
+			s, _ = newSorter(a.buffer, a.tsIndex)
+			a.sorters[name] = s
+		}
+		closed = append(closed, a.aggregate(name, s.add(groups[t]))...)
+	}
+	return closed
+}
+
+// flush 聚合排序缓存中的行，返回所有未输出的窗口
+func (a *aggregator) flush() []row {
+	tables := make([]string, 0, len(a.sorters))
+	for table := range a.sorters {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	var rows []row
+	for _, table := range tables {
+		rows = append(rows, a.aggregate(table, a.sorters[table].flush())...)
+		rows = append(rows, a.emit(table, 0)...)
+		delete(a.sorters, table)
+	}
+	return rows
+}
+
+// aggregate 聚合 table 中按时间戳排好序的行，返回已经结束的窗口
+func (a *aggregator) aggregate(table string, rows []row) []row {
+	var closed []row
+
+	for _, r := range rows {
+		ts, ok := r.values[a.tsIndex].(time.Time)
+		if !ok {
+			continue
+		}
+
+		start := common.TruncateTime(ts, a.window)
+		key := start.UnixNano()
+		current, exist := a.current[table]
+		if exist && key < current {
+			a.late++
+			continue
+		}
+		if !exist || key > current {
+			closed = append(closed, a.emit(table, key)...)
+			a.current[table] = key
+		}
+
+		bars, ok := a.bars[table]
+		if !ok {
+			bars = make(map[int64]*bar)
+			a.bars[table] = bars
+		}
+		b, ok := bars[key]
+		if !ok {
+			b = &bar{table: table + a.suffix, start: start, states: make([]aggState, len(a.columns))}
+			bars[key] = b
+		}
+		a.update(b, ts, r)
+	}
+
+	return closed
+}
+
+// emit 输出并释放 table 中开始时间早于 before 的窗口，before 为 0 时输出全部
+func (a *aggregator) emit(table string, before int64) []row {
+	var keys []int64
+	for key := range a.bars[table] {
+		if before == 0 || key < before {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+
+	rows := make([]row, 0, len(keys))
+	for _, key := range keys {
+		b := a.bars[table][key]
+		delete(a.bars[table], key)
+		rows = append(rows, row{table: b.table, values: a.values(b)})
+	}
+	if len(a.bars[table]) == 0 {
+		delete(a.bars, table)
+	}
+	return rows
+}
+
+func (a *aggregator) update(b *bar, ts time.Time, r row) {
+	for i, column := range a.columns {
+		s := &b.states[i]
+		// 没有 source 的 count 为 count(*)，有 source 时与 count(col) 相同，不计 null
+		if column.index < 0 {
+			s.count++
+			continue
+		}
+
+		value := r.values[column.index]
+		if value == nil {
+			continue
+		}
+		s.count++
+		if s.first == nil || ts.Before(s.firstTs) {
+			s.first, s.firstTs = value, ts
+		}
+		if s.last == nil || !ts.Before(s.lastTs) {
+			s.last, s.lastTs = value, ts
+		}
+
+		if column.fn != AggMin && column.fn != AggMax && column.fn != AggSum && column.fn != AggAvg {
+			continue
+		}
+		v, err := common.Float64(value)
+		if err != nil {
+			log.Printf("## aggregate line-[%d] value [%v] is not a number", r.line, value)
+			continue
+		}
+		if s.numbers == 0 || v < s.min {
+			s.min = v
+		}
+		if s.numbers == 0 || v > s.max {
+			s.max = v
+		}
+		s.sum += v
+		s.numbers++
+	}
+}
+
+func (a *aggregator) values(b *bar) []any {
+	values := make([]any, 0, len(a.columns)+1)
+	values = append(values, b.start)
+
+	for i, column := range a.columns {
+		s := b.states[i]
+		switch column.fn {
+		case AggFirst:
+			values = append(values, s.first)
+		case AggLast:
+			values = append(values, s.last)
+		case AggCount:
+			values = append(values, s.count)
+		default:
+			if s.numbers == 0 {
+				values = append(values, nil)
+				continue
+			}
+			switch column.fn {
+			case AggMin:
+				values = append(values, s.min)
+			case AggMax:
+				values = append(values, s.max)
+			case AggSum:
+				values = append(values, s.sum)
+			case AggAvg:
+				values = append(values, s.sum/float64(s.numbers))
+			}
+		}
+	}
+	return values
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"reflect"
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+)
+
+func TestAggregator(t *testing.T) {
+	base := time.Date(2022, 11, 23, 9, 30, 0, 0, time.UTC)
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}, {Field: "price", Type: "int"}, {Field: "volume", Type: "int"}}
+	conf := config.Aggregate{
+		STable: "kline",
+		Window: "1m",
+		Buffer: 1,
+		Columns: []config.AggregateColumn{
+			{Field: "open", Func: AggFirst, Source: "price"},
+			{Field: "high", Func: AggMax, Source: "price"},
+			{Field: "low", Func: AggMin, Source: "price"},
+			{Field: "close", Func: AggLast, Source: "price"},
+			{Field: "volume", Func: AggSum, Source: "volume"},
+			{Field: "count", Func: AggCount},
+		},
+	}
+	a, err := newAggregator(conf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows := []row{
+		{values: []any{at(1), "10", "100"}},
+		{values: []any{at(30), "12", "200"}},
+		{values: []any{at(59), "9", "300"}},
+		{values: []any{at(61), "11", "10"}},
+		{values: []any{at(62), "12", "20"}},
+	}
+	bars := a.add(rows, "t_600000")
+	expect := []row{{table: "t_600000_1m", values: []any{at(0), "10", 12.0, 9.0, "9", 600.0, int64(3)}}}
+	if !reflect.DeepEqual(bars, expect) {
+		t.Fatalf("expect-[%v] but got-[%v]", expect, bars)
+	}
+	if len(a.bars["t_600000"]) != 1 {
+		t.Fatalf("output windows should be released, got-[%v]", a.bars)
+	}
+
+	bars = a.flush()
+	expect = []row{{table: "t_600000_1m", values: []any{at(60), "11", 12.0, 11.0, "12", 30.0, int64(2)}}}
+	if !reflect.DeepEqual(bars, expect) {
+		t.Fatalf("expect-[%v] but got-[%v]", expect, bars)
+	}
+	if bars = a.flush(); len(bars) != 0 || len(a.bars) != 0 || len(a.sorters) != 0 {
+		t.Fatalf("flush twice should return nothing, got-[%v]", bars)
+	}
+}
+
+// count 有 source 时与 count(col) 一样不计 null，没有 source 时为 count(*)
+func TestAggregator_CountNull(t *testing.T) {
+	base := time.Date(2022, 11, 23, 9, 30, 0, 0, time.UTC)
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}, {Field: "volume", Type: "int"}}
+	conf := config.Aggregate{
+		STable: "kline",
+		Window: "1m",
+		Columns: []config.AggregateColumn{
+			{Field: "rows", Func: AggCount},
+			{Field: "trades", Func: AggCount, Source: "volume"},
+		},
+	}
+	a, err := newAggregator(conf, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a.add([]row{
+		{values: []any{base.Add(time.Second), "100"}},
+		{values: []any{base.Add(2 * time.Second), nil}},
+		{values: []any{base.Add(3 * time.Second), "300"}},
+	}, "t_600000")
+	bars := a.flush()
+	expect := []row{{table: "t_600000_1m", values: []any{base, int64(3), int64(2)}}}
+	if !reflect.DeepEqual(bars, expect) {
+		t.Fatalf("expect-[%v] but got-[%v]", expect, bars)
+	}
+}
+
+func TestAggregator_Unordered(t *testing.T) {
+	base := time.Date(2022, 11, 23, 9, 30, 0, 0, time.UTC)
+	at := func(s int) time.Time { return base.Add(time.Duration(s) * time.Second) }
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}, {Field: "price", Type: "int"}}
+
+	cases := []struct {
+		name   string
+		buffer int
+		ts     []int
+		expect []row
+		late   int64
+	}{
+		{
+			name:   "in buffer",
+			buffer: 2,
+			ts:     []int{1, 61, 30, 120},
+			expect: []row{
+				{table: "t_1m", values: []any{at(0), int64(2)}},
+				{table: "t_1m", values: []any{at(60), int64(1)}},
+				{table: "t_1m", values: []any{at(120), int64(1)}},
+			},
+		},
+		{
+			name:   "later than buffer",
+			buffer: 1,
+			ts:     []int{61, 62, 1},
+			expect: []row{{table: "t_1m", values: []any{at(60), int64(2)}}},
+			late:   1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf := config.Aggregate{STable: "kline", Window: "1m", Buffer: c.buffer, Columns: []config.AggregateColumn{{Field: "count", Func: AggCount}}}
+			a, err := newAggregator(conf, columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var rows []row
+			for _, s := range c.ts {
+				rows = append(rows, row{values: []any{at(s), "1"}})
+			}
+			bars := append(a.add(rows, "t"), a.flush()...)
+			if !reflect.DeepEqual(bars, c.expect) {
+				t.Fatalf("expect-[%v] but got-[%v]", c.expect, bars)
+			}
+			if a.late != c.late {
+				t.Fatalf("late error. expect-[%d] but got-[%d]", c.late, a.late)
+			}
+		})
+	}
+}
+
+func TestNewAggregator(t *testing.T) {
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}, {Field: "price", Type: "int"}}
+
+	cases := []struct {
+		name string
+		conf config.Aggregate
+	}{
+		{name: "mode", conf: config.Aggregate{STable: "k", Window: "1m", Mode: "replace"}},
+		{name: "window", conf: config.Aggregate{STable: "k", Window: "1x"}},
+		{name: "func", conf: config.Aggregate{STable: "k", Window: "1m", Columns: []config.AggregateColumn{{Field: "p", Func: "median", Source: "price"}}}},
+		{name: "source", conf: config.Aggregate{STable: "k", Window: "1m", Columns: []config.AggregateColumn{{Field: "p", Func: AggMax, Source: "volume"}}}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := newAggregator(c.conf, columns); err == nil {
+				t.Fatal("expect error")
+			}
+		})
+	}
+
+	if a, err := newAggregator(config.Aggregate{}, columns); a != nil || err != nil {
+		t.Fatal("empty stable should disable aggregate")
+	}
+}
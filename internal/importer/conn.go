@@ -0,0 +1,53 @@
+package importer
+
+import (
+	"fmt"
+	"taos_importer/internal/config"
+
+	"github.com/taosdata/driver-go/v3/common/param"
+)
+
+// insertStmt 一次 STMT 写入, native 和 websocket 的调用顺序相同
+type insertStmt interface {
+	Prepare(sql string) error
+	SetTableName(name string) error
+	BindParam(params []*param.Param, bindType *param.ColumnType) error
+	AddBatch() error
+	Execute() error
+	Close() error
+}
+
+// connector 写入数据的连接, 从连接上创建 STMT 写入, ping 用于连接池检查连接是否可用
+type connector interface {
+	insertStmt() (insertStmt, error)
+	ping() error
+	Close() error
+}
+
+// rowWriter 写入一批行
+type rowWriter interface {
+	write(rows []row) error
+}
+
+// Sink 写入的目标，Pool 写入 TDengine，SqlFile 写入 SQL 脚本，CsvFile 按子表写入 csv 文件
+type Sink interface {
+	// writer 返回写入 table 的 rowWriter，table 为空时按 row.table 写入
+	writer(db string, table string, columns []config.Column, precision string) (rowWriter, error)
+	// ordered 为 true 时按批次顺序写入，输出是确定的
+	ordered() bool
+	Close() error
+}
+
+// openConnector 按 tdengine.protocol 打开写入连接
+func openConnector(conf config.Config) (connector, error) {
+	switch conf.TDEngine.GetProtocol() {
+	case config.ProtocolNative:
+		return openNative(conf)
+	case config.ProtocolWS:
+		return openWS(conf)
+	case config.ProtocolRest:
+		return openRest(conf)
+	default:
+		return nil, fmt.Errorf("unknown protocol [%s]", conf.TDEngine.Protocol)
+	}
+}
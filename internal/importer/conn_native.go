@@ -0,0 +1,38 @@
+//go:build !purego
+
+package importer
+
+import (
+	"taos_importer/internal/config"
+
+	"github.com/taosdata/driver-go/v3/af"
+)
+
+type nativeConnector struct {
+	conn *af.Connector
+}
+
+// openNative 连接不指定库，写入的语句都带库名，库不存在时也能打开，之后再建库
+func openNative(conf config.Config) (connector, error) {
+	conn, err := af.Open(conf.TDEngine.Host, conf.TDEngine.User, conf.TDEngine.Password, "", conf.TDEngine.GetPort())
+	if err != nil {
+		return nil, err
+	}
+	return &nativeConnector{conn: conn}, nil
+}
+
+func (c *nativeConnector) insertStmt() (insertStmt, error) {
+	return c.conn.InsertStmt(), nil
+}
+
+func (c *nativeConnector) ping() error {
+	rows, err := c.conn.Query("select server_version()")
+	if err != nil {
+		return err
+	}
+	return rows.Close()
+}
+
+func (c *nativeConnector) Close() error {
+	return c.conn.Close()
+}
@@ -0,0 +1,13 @@
+//go:build purego
+
+package importer
+
+import (
+	"fmt"
+	"taos_importer/internal/config"
+)
+
+// openNative purego 编译不包含 cgo 驱动
+func openNative(_ config.Config) (connector, error) {
+	return nil, fmt.Errorf("protocol native is not supported in purego build, use ws")
+}
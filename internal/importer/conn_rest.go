@@ -0,0 +1,85 @@
+package importer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"taos_importer/internal/config"
+	"time"
+)
+
+const restTimeout = 5 * time.Minute
+
+// restConnector 通过 taosAdapter 的 /rest/sql 接口执行 SQL, 不支持 STMT, 写入使用 sqlWriter
+type restConnector struct {
+	url    string
+	header http.Header
+	client *http.Client
+}
+
+func openRest(conf config.Config) (connector, error) {
+	u := url.URL{Scheme: "http", Host: fmt.Sprintf("%s:%d", conf.TDEngine.Host, conf.TDEngine.GetPort()), Path: "/rest/sql"}
+	header := http.Header{}
+	header.Set("Content-Type", "text/plain; charset=utf-8")
+	if len(conf.TDEngine.Token) > 0 {
+		u.RawQuery = url.Values{"token": {conf.TDEngine.Token}}.Encode()
+	} else {
+		auth := base64.StdEncoding.EncodeToString([]byte(conf.TDEngine.User + ":" + conf.TDEngine.Password))
+		header.Set("Authorization", "Basic "+auth)
+	}
+	return &restConnector{
+		url:    u.String(),
+		header: header,
+		client: &http.Client{Timeout: restTimeout},
+	}, nil
+}
+
+func (c *restConnector) insertStmt() (insertStmt, error) {
+	return nil, fmt.Errorf("protocol rest does not support stmt")
+}
+
+// exec 执行一条 SQL, 返回的 code 不为 0 时返回 error
+func (c *restConnector) exec(sql string) error {
+	req, err := http.NewRequest(http.MethodPost, c.url, strings.NewReader(sql))
+	if err != nil {
+		return err
+	}
+	req.Header = c.header.Clone()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result struct {
+		Code int    `json:"code"`
+		Desc string `json:"desc"`
+	}
+	if err = json.Unmarshal(body, &result); err != nil {
+		return fmt.Errorf("http status-[%d] %s", resp.StatusCode, body)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("code-[%#x] %s", result.Code, result.Desc)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status-[%d] %s", resp.StatusCode, body)
+	}
+	return nil
+}
+
+func (c *restConnector) ping() error {
+	return c.exec("select server_version()")
+}
+
+func (c *restConnector) Close() error {
+	c.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,62 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"taos_importer/internal/config"
+
+	"github.com/taosdata/driver-go/v3/ws/stmt"
+)
+
+// wsSendChanLength websocket 发送队列长度
+const wsSendChanLength = 64
+
+// wsConnector 通过 taosAdapter 的 /rest/stmt 接口写入
+type wsConnector struct {
+	conn *stmt.Connector
+}
+
+// openWS 与 openNative 相同，连接不指定库
+func openWS(conf config.Config) (connector, error) {
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", conf.TDEngine.Host, conf.TDEngine.GetPort()), Path: "/rest/stmt"}
+	if len(conf.TDEngine.Token) > 0 {
+		u.RawQuery = url.Values{"token": {conf.TDEngine.Token}}.Encode()
+	}
+	stmtConf := stmt.NewConfig(u.String(), wsSendChanLength)
+	_ = stmtConf.SetConnectUser(conf.TDEngine.User)
+	_ = stmtConf.SetConnectPass(conf.TDEngine.Password)
+	conn, err := stmt.NewConnector(stmtConf)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s error %v", u.String(), err)
+	}
+	return &wsConnector{conn: conn}, nil
+}
+
+func (c *wsConnector) insertStmt() (insertStmt, error) {
+	s, err := c.conn.Init()
+	if err != nil {
+		return nil, err
+	}
+	return wsStmt{Stmt: s}, nil
+}
+
+// ping taosAdapter 的 stmt 接口没有查询，创建并关闭一个 STMT
+func (c *wsConnector) ping() error {
+	s, err := c.conn.Init()
+	if err != nil {
+		return err
+	}
+	return s.Close()
+}
+
+func (c *wsConnector) Close() error {
+	return c.conn.Close()
+}
+
+type wsStmt struct {
+	*stmt.Stmt
+}
+
+func (s wsStmt) Execute() error {
+	return s.Exec()
+}
@@ -0,0 +1,217 @@
+package importer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"sync"
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// fakeAdapter 模拟 taosAdapter 的 /rest/stmt 接口，记录收到的 action，failOn 中的 action 返回错误。
+// 还没有创建任何库，conn 指定库时返回错误
+type fakeAdapter struct {
+	mu      sync.Mutex
+	actions []string
+	args    []map[string]any
+	failOn  string
+}
+
+func (a *fakeAdapter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/rest/stmt" {
+		http.NotFound(w, r)
+		return
+	}
+	upgrader := websocket.Upgrader{}
+	ws, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer func() { _ = ws.Close() }()
+
+	for {
+		t, message, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		var action string
+		var reqID uint64
+		args := make(map[string]any)
+		if t == websocket.BinaryMessage {
+			// req_id, stmt_id, 消息类型, 之后是数据块
+			action = "bind"
+			reqID = binary.LittleEndian.Uint64(message)
+		} else {
+			var req struct {
+				Action string         `json:"action"`
+				Args   map[string]any `json:"args"`
+			}
+			if err = json.Unmarshal(message, &req); err != nil {
+				return
+			}
+			action, args = req.Action, req.Args
+			if id, ok := args["req_id"].(float64); ok {
+				reqID = uint64(id)
+			}
+		}
+
+		a.mu.Lock()
+		a.actions = append(a.actions, action)
+		a.args = append(a.args, args)
+		a.mu.Unlock()
+
+		if action == "close" {
+			continue
+		}
+		resp := map[string]any{"code": 0, "action": action, "req_id": reqID, "stmt_id": 1}
+		if action == a.failOn {
+			resp["code"] = 0x2603
+			resp["message"] = "Table does not exist"
+		}
+		if db, _ := args["db"].(string); action == "conn" && len(db) > 0 {
+			resp["code"] = 0x388
+			resp["message"] = "Database not exist"
+		}
+		if err = ws.WriteJSON(resp); err != nil {
+			return
+		}
+	}
+}
+
+func (a *fakeAdapter) recorded() ([]string, []map[string]any) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]string(nil), a.actions...), append([]map[string]any(nil), a.args...)
+}
+
+func TestWsConnector(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "double"},
+		{Field: "code", Type: "varchar(16)"},
+	}
+	ts := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{ts, "1.5", "a"}},
+		{line: 2, table: "t_2", values: []any{ts, "2.5", nil}},
+		{line: 3, table: "t_1", values: []any{ts.Add(time.Second), "3.5", "b"}},
+	}
+
+	cases := []struct {
+		name    string
+		failOn  string
+		writes  int
+		err     bool
+		actions []string
+		tables  []string
+	}{
+		{
+			name:    "write",
+			writes:  1,
+			actions: []string{"conn", "init", "prepare", "set_table_name", "bind", "add_batch", "set_table_name", "bind", "add_batch", "exec"},
+			tables:  []string{"`stock`.`t_1`", "`stock`.`t_2`"},
+		},
+		{
+			// 第二次写入复用连接和 prepare 过的 STMT
+			name:    "reuse",
+			writes:  2,
+			actions: []string{"conn", "init", "prepare", "set_table_name", "bind", "add_batch", "set_table_name", "bind", "add_batch", "exec", "set_table_name", "bind", "add_batch", "set_table_name", "bind", "add_batch", "exec"},
+			tables:  []string{"`stock`.`t_1`", "`stock`.`t_2`", "`stock`.`t_1`", "`stock`.`t_2`"},
+		},
+		{
+			name:    "error",
+			failOn:  "set_table_name",
+			writes:  1,
+			err:     true,
+			actions: []string{"conn", "init", "prepare", "set_table_name"},
+			tables:  []string{"`stock`.`t_1`"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			adapter := &fakeAdapter{failOn: c.failOn}
+			server := httptest.NewServer(adapter)
+			defer server.Close()
+
+			host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := config.Config{DB: config.Database{Name: "stock", Precision: "ms"}}
+			conf.TDEngine = config.TDEngine{Protocol: config.ProtocolWS, Host: host, User: "root", Password: "taosdata"}
+			if conf.TDEngine.Port, err = strconv.Atoi(port); err != nil {
+				t.Fatal(err)
+			}
+
+			pool, err := NewPool(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = pool.Close() }()
+
+			w, err := newStmtWriter(pool, conf.DB.Name, "", columns, conf.DB.Precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < c.writes; i++ {
+				if err = w.write(rows); (err != nil) != c.err {
+					t.Fatalf("write error %v, expect error %v", err, c.err)
+				}
+			}
+
+			actions, args := adapter.recorded()
+			// close 不等待响应，不一定已经收到
+			if len(actions) > 0 && actions[len(actions)-1] == "close" {
+				actions = actions[:len(actions)-1]
+			}
+			if !reflect.DeepEqual(actions, c.actions) {
+				t.Errorf("actions %v, expect %v", actions, c.actions)
+			}
+			// 连接不指定库，库还没有创建时也能打开连接池
+			if db, _ := args[0]["db"].(string); len(db) > 0 || args[0]["user"] != "root" {
+				t.Errorf("conn args %v", args[0])
+			}
+			if args[2]["sql"] != w.insertSql {
+				t.Errorf("prepare sql %v, expect %s", args[2]["sql"], w.insertSql)
+			}
+			tables := make([]string, 0)
+			for i, action := range actions {
+				if action == "set_table_name" {
+					tables = append(tables, args[i]["name"].(string))
+				}
+			}
+			if !reflect.DeepEqual(tables, c.tables) {
+				t.Errorf("tables %v, expect %v", tables, c.tables)
+			}
+		})
+	}
+}
+
+func TestOpenConnector(t *testing.T) {
+	cases := []struct {
+		name     string
+		protocol string
+	}{
+		{name: "unknown", protocol: "mqtt"},
+		{name: "ws unreachable", protocol: config.ProtocolWS},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// 端口 1 上没有服务
+			conf := config.Config{TDEngine: config.TDEngine{Protocol: c.protocol, Host: "127.0.0.1", Port: 1}}
+			if _, err := openConnector(conf); err == nil {
+				t.Errorf("expect error of protocol [%s]", c.protocol)
+			}
+		})
+	}
+}
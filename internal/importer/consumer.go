@@ -0,0 +1,71 @@
+package importer
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Consumer TMQ 的消费者，wsConsumer 通过 taosAdapter 订阅，测试时替换为假的实现
+type Consumer interface {
+	Subscribe(topics []string) error
+	// Poll 等待 timeout 没有消息时返回 nil
+	Poll(timeout time.Duration) (*Message, error)
+	Commit(message *Message) error
+	Close() error
+}
+
+// Message 一次消费到的数据，每个子表的行按主题的列顺序
+type Message struct {
+	ID     uint64
+	Topic  string
+	DB     string
+	Tables []TableRows
+}
+
+type TableRows struct {
+	Table string
+	Rows  [][]any
+}
+
+// TableRate 一个子表在一段时间内消费的行数和每秒的行数
+type TableRate struct {
+	Table     string
+	Rows      int64
+	PerSecond float64
+}
+
+// Rates 按子表统计消费的行数，Take 返回上次 Take 以来的结果
+type Rates struct {
+	mu     sync.Mutex
+	start  time.Time
+	counts map[string]int64
+}
+
+func NewRates(start time.Time) *Rates {
+	return &Rates{start: start, counts: make(map[string]int64)}
+}
+
+func (r *Rates) add(table string, rows int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.counts[table] += int64(rows)
+}
+
+// Take 返回 now 之前每个子表的速率，按子表名排序，之后重新计数
+func (r *Rates) Take(now time.Time) []TableRate {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	seconds := now.Sub(r.start).Seconds()
+	rates := make([]TableRate, 0, len(r.counts))
+	for table, rows := range r.counts {
+		rate := TableRate{Table: table, Rows: rows}
+		if seconds > 0 {
+			rate.PerSecond = float64(rows) / seconds
+		}
+		rates = append(rates, rate)
+	}
+	sort.Slice(rates, func(i, j int) bool { return rates[i].Table < rates[j].Table })
+	r.start, r.counts = now, make(map[string]int64)
+	return rates
+}
@@ -0,0 +1,72 @@
+package importer
+
+import (
+	"fmt"
+	"net/url"
+	"taos_importer/internal/config"
+	"time"
+
+	"github.com/taosdata/driver-go/v3/ws/tmq"
+)
+
+// wsConsumer 通过 taosAdapter 的 /rest/tmq 接口订阅
+type wsConsumer struct {
+	consumer *tmq.Consumer
+}
+
+// NewWSConsumer 用 group 消费，offsetReset 为 earliest 或 latest，group 第一次订阅时从这里开始。
+// native 连接时 taosAdapter 使用默认端口 6041
+func NewWSConsumer(conf config.Config, group string, offsetReset string) (Consumer, error) {
+	port := conf.TDEngine.GetPort()
+	if conf.TDEngine.GetProtocol() == config.ProtocolNative {
+		port = 6041
+	}
+	u := url.URL{Scheme: "ws", Host: fmt.Sprintf("%s:%d", conf.TDEngine.Host, port), Path: "/rest/tmq"}
+	if len(conf.TDEngine.Token) > 0 {
+		u.RawQuery = url.Values{"token": {conf.TDEngine.Token}}.Encode()
+	}
+	tmqConf := tmq.NewConfig(u.String(), wsSendChanLength)
+	_ = tmqConf.SetConnectUser(conf.TDEngine.User)
+	_ = tmqConf.SetConnectPass(conf.TDEngine.Password)
+	_ = tmqConf.SetGroupID(group)
+	_ = tmqConf.SetClientID("taos_importer")
+	_ = tmqConf.SetAutoOffsetReset(offsetReset)
+	consumer, err := tmq.NewConsumer(tmqConf)
+	if err != nil {
+		return nil, fmt.Errorf("connect to %s error %v", u.String(), err)
+	}
+	return &wsConsumer{consumer: consumer}, nil
+}
+
+func (c *wsConsumer) Subscribe(topics []string) error {
+	return c.consumer.Subscribe(topics)
+}
+
+// Poll 只有元数据的消息返回没有子表的 Message，需要 commit
+func (c *wsConsumer) Poll(timeout time.Duration) (*Message, error) {
+	result, err := c.consumer.Poll(timeout)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	message := &Message{ID: result.Message, Topic: result.Topic, DB: result.DBName, Tables: make([]TableRows, 0, len(result.Data))}
+	for _, data := range result.Data {
+		rows := make([][]any, 0, len(data.Data))
+		for _, values := range data.Data {
+			r := make([]any, len(values))
+			for i, v := range values {
+				r[i] = v
+			}
+			rows = append(rows, r)
+		}
+		message.Tables = append(message.Tables, TableRows{Table: data.TableName, Rows: rows})
+	}
+	return message, nil
+}
+
+func (c *wsConsumer) Commit(message *Message) error {
+	return c.consumer.Commit(message.ID)
+}
+
+func (c *wsConsumer) Close() error {
+	return c.consumer.Close()
+}
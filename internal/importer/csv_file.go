@@ -0,0 +1,215 @@
+package importer
+
+import (
+	"bufio"
+	"container/list"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"time"
+)
+
+const (
+	// maxOpenCsvFiles 同时打开的 csv 文件数，超出时关闭最久未写入的，再写入时追加
+	maxOpenCsvFiles = 64
+	// ManifestFile 导出目录下记录所有 csv 文件的清单
+	ManifestFile = "manifest.json"
+)
+
+// CsvFile 按子表把数据写为 <库名>/<子表名>.csv，之后用 insert into ... file 导入。
+// 没有表头，时间戳为 db 精度的整数，其他值的格式和 insert 语句相同。关闭时写入 manifest.json
+type CsvFile struct {
+	mu     sync.Mutex
+	dir    string
+	tables map[string]*csvTable // db.table
+	open   *list.List           // 打开的 *csvTable，最近写入的在前
+}
+
+// CsvTableFile 一个子表的 csv 文件，File 为绝对路径
+type CsvTableFile struct {
+	DB    string `json:"db"`
+	Table string `json:"table"`
+	File  string `json:"file"`
+	Rows  int64  `json:"rows"`
+}
+
+type csvTable struct {
+	CsvTableFile
+	file   *os.File
+	buffer *bufio.Writer
+	elem   *list.Element
+}
+
+func NewCsvFile(dir string) (*CsvFile, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &CsvFile{dir: dir, tables: make(map[string]*csvTable), open: list.New()}, nil
+}
+
+func (s *CsvFile) writer(db string, table string, columns []config.Column, precision string) (rowWriter, error) {
+	if len(precision) == 0 {
+		precision = common.PrecisionMilliSecond
+	}
+	return &csvFileWriter{file: s, db: db, table: table, columns: columns, timeUnit: precision}, nil
+}
+
+func (s *CsvFile) ordered() bool {
+	return true
+}
+
+// write 追加 rows 行到 table 的文件，第一次写入时创建文件
+func (s *CsvFile) write(db string, table string, data string, rows int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := db + "." + table
+	t, ok := s.tables[key]
+	flag := os.O_WRONLY | os.O_APPEND
+	if !ok {
+		t = &csvTable{CsvTableFile: CsvTableFile{DB: db, Table: table, File: filepath.Join(s.dir, db, table+".csv")}}
+		if err := os.MkdirAll(filepath.Dir(t.File), 0755); err != nil {
+			return err
+		}
+		s.tables[key] = t
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	if t.file == nil {
+		f, err := os.OpenFile(t.File, flag, 0644)
+		if err != nil {
+			return err
+		}
+		t.file, t.buffer = f, bufio.NewWriter(f)
+		t.elem = s.open.PushFront(t)
+		if s.open.Len() > maxOpenCsvFiles {
+			if err = s.closeFile(s.open.Back().Value.(*csvTable)); err != nil {
+				return err
+			}
+		}
+	} else {
+		s.open.MoveToFront(t.elem)
+	}
+
+	if _, err := t.buffer.WriteString(data); err != nil {
+		return err
+	}
+	t.Rows += int64(rows)
+	return nil
+}
+
+func (s *CsvFile) closeFile(t *csvTable) error {
+	s.open.Remove(t.elem)
+	f, buffer := t.file, t.buffer
+	t.file, t.buffer, t.elem = nil, nil, nil
+	if err := buffer.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Files 返回所有子表的文件，按文件名排序
+func (s *CsvFile) Files() []CsvTableFile {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	files := make([]CsvTableFile, 0, len(s.tables))
+	for _, t := range s.tables {
+		files = append(files, t.CsvTableFile)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].File < files[j].File })
+	return files
+}
+
+// Manifest 返回 manifest.json 的路径
+func (s *CsvFile) Manifest() string {
+	return filepath.Join(s.dir, ManifestFile)
+}
+
+// Close 关闭所有文件并写入 manifest.json
+func (s *CsvFile) Close() error {
+	s.mu.Lock()
+	for s.open.Len() > 0 {
+		if err := s.closeFile(s.open.Front().Value.(*csvTable)); err != nil {
+			s.mu.Unlock()
+			return err
+		}
+	}
+	s.mu.Unlock()
+
+	b, err := json.MarshalIndent(s.Files(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Manifest(), b, 0644)
+}
+
+type csvFileWriter struct {
+	file     *CsvFile
+	db       string
+	table    string
+	columns  []config.Column
+	timeUnit string
+}
+
+func (w *csvFileWriter) write(rows []row) error {
+	tables, groups := groupByTable(rows)
+	for _, table := range tables {
+		name := w.table
+		if len(name) == 0 {
+			if len(table) == 0 {
+				return fmt.Errorf("table name of line-[%d] is null", groups[table][0].line)
+			}
+			name = table
+		}
+		if strings.ContainsAny(name, `/\`) {
+			return fmt.Errorf("table name [%s] can not be a file name", name)
+		}
+
+		var buffer strings.Builder
+		for _, r := range groups[table] {
+			line, err := w.line(r)
+			if err != nil {
+				return fmt.Errorf("parse params error %v", err)
+			}
+			buffer.WriteString(line)
+			buffer.WriteString("\n")
+		}
+		if err := w.file.write(w.db, name, buffer.String(), len(groups[table])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// line 渲染一行为 v1,v2,...，时间戳为整数，转换失败时返回的 error 包含行号和列名
+func (w *csvFileWriter) line(r row) (string, error) {
+	fields := make([]string, 0, len(w.columns))
+	for i, column := range w.columns {
+		value, err := common.Convert(r.values[i], column.Type, w.timeUnit)
+		if err != nil {
+			return "", fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+		}
+		if t, ok := value.(time.Time); ok {
+			ts, err := common.ToUnix(t, w.timeUnit)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, fmt.Sprint(ts))
+			continue
+		}
+		literal, err := db_table.Literal(value, column.Type, w.timeUnit)
+		if err != nil {
+			return "", fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+		}
+		fields = append(fields, literal)
+	}
+	return strings.Join(fields, ","), nil
+}
@@ -0,0 +1,176 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"taos_importer/internal/config"
+	"testing"
+)
+
+func TestCsvFile(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "double"},
+		{Field: "name", Type: "nchar(16)"},
+	}
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{"1669196785000", "1.5", "a'b"}},
+		{line: 2, table: "t_2", values: []any{"1669196786000", "2", nil}},
+		{line: 3, table: "t_1", values: []any{"1669196787000", "3.25", "c"}},
+	}
+
+	cases := []struct {
+		name      string
+		table     string
+		precision string
+		batches   [][]row
+		expect    map[string]string
+		rows      map[string]int64
+		err       bool
+	}{
+		{
+			name:      "per table",
+			precision: "ms",
+			batches:   [][]row{rows, rows[2:]},
+			expect: map[string]string{
+				"stock/t_1.csv": "1669196785000,1.5,'a\\'b'\n1669196787000,3.25,'c'\n1669196787000,3.25,'c'\n",
+				"stock/t_2.csv": "1669196786000,2,NULL\n",
+			},
+			rows: map[string]int64{"t_1": 3, "t_2": 1},
+		},
+		{
+			name:      "writer table",
+			table:     "t_9",
+			precision: "us",
+			batches:   [][]row{{{line: 1, values: []any{"1669196785000001", "1", "x"}}}},
+			expect:    map[string]string{"stock/t_9.csv": "1669196785000001,1,'x'\n"},
+			rows:      map[string]int64{"t_9": 1},
+		},
+		{
+			name:    "default precision",
+			batches: [][]row{rows[1:2]},
+			expect:  map[string]string{"stock/t_2.csv": "1669196786000,2,NULL\n"},
+			rows:    map[string]int64{"t_2": 1},
+		},
+		{
+			name:    "null table",
+			batches: [][]row{{{line: 1, values: rows[0].values}}},
+			err:     true,
+		},
+		{
+			name:    "convert error",
+			batches: [][]row{{{line: 1, table: "t_1", values: []any{"1669196785000", "abc", "x"}}}},
+			err:     true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := NewCsvFile(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			w, err := s.writer("stock", c.table, columns, c.precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, batch := range c.batches {
+				if err = w.write(batch); err != nil {
+					break
+				}
+			}
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if err = s.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if c.err {
+				return
+			}
+
+			content := make(map[string]string)
+			counts := make(map[string]int64)
+			for _, f := range s.Files() {
+				b, err := os.ReadFile(f.File)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rel, _ := filepath.Rel(dir, f.File)
+				content[filepath.ToSlash(rel)] = string(b)
+				counts[f.Table] = f.Rows
+			}
+			if !reflect.DeepEqual(content, c.expect) {
+				t.Errorf("\n%v\nexpect\n%v", content, c.expect)
+			}
+			if !reflect.DeepEqual(counts, c.rows) {
+				t.Errorf("rows %v, expect %v", counts, c.rows)
+			}
+			if _, err = os.Stat(s.Manifest()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+// 不同库中同名的子表写入不同的文件
+func TestCsvFile_SameTableInDBs(t *testing.T) {
+	s, err := NewCsvFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, db := range []string{"stock", "stock_copy"} {
+		if err = s.write(db, "t_1", db+"\n", 1); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := s.Files()
+	if len(files) != 2 || files[0].File == files[1].File {
+		t.Fatalf("files error %+v", files)
+	}
+	for _, f := range files {
+		if b, err := os.ReadFile(f.File); err != nil || string(b) != f.DB+"\n" {
+			t.Fatalf("file [%s] of db [%s] error [%s] %v", f.File, f.DB, b, err)
+		}
+	}
+}
+
+// 超过 maxOpenCsvFiles 后关闭的文件再次写入时追加
+func TestCsvFile_Reopen(t *testing.T) {
+	s, err := NewCsvFile(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		for n := 0; n <= maxOpenCsvFiles; n++ {
+			if err = s.write("stock", fmt.Sprintf("t_%d", n), "1,2\n", 1); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if s.open.Len() != maxOpenCsvFiles {
+		t.Errorf("open files %d", s.open.Len())
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files := s.Files()
+	if len(files) != maxOpenCsvFiles+1 {
+		t.Fatalf("files %d", len(files))
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f.File)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != "1,2\n1,2\n" || f.Rows != 2 {
+			t.Errorf("%s %q rows %d", f.File, b, f.Rows)
+		}
+	}
+}
@@ -1,353 +1,486 @@
 package importer
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"log"
-	"strconv"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"taos_importer/internal/common"
 	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
 	"taos_importer/internal/field"
 	"time"
-
-	"github.com/taosdata/driver-go/v3/af"
-	common2 "github.com/taosdata/driver-go/v3/common"
-	"github.com/taosdata/driver-go/v3/common/param"
-	_ "github.com/taosdata/driver-go/v3/taosSql"
 )
 
 type CsvImporter struct {
-	conn        *af.Connector
-	db          string
-	table       string
-	columns     []config.Column
-	concurrent  int
-	batchSize   int
-	precision   int
-	columnTypes *param.ColumnType
-	insertSql   string
-	locker      sync.Mutex
-	extractor   *field.Extractor
+	db         string
+	table      string
+	columns    []config.Column
+	concurrent int
+	batchSize  int
+	timeUnit   string
+	tsIndex    int // 时间戳列下标
+	writer     rowWriter
+	extractor  *field.Extractor
+	dedup      config.Dedup        // strategy 已按旧配置补全
+	dedups     map[string]*deduper // 每个子表一个，只在 sequence 中访问
+	sort       config.Sort
+	sorters    map[string]*sorter // 每个子表一个，只在 sequence 中访问
+	sequence   sequencer
+	explode    *exploder
+	naming     db_table.NamingPolicy // explode.table 生成的子表名按 tag 文件的规则规范化
+	filter     string
+	from       *time.Time
+	to         *time.Time
+	aggregate  *aggregator // 只在 sequence 中访问
+	barWriter  rowWriter
+	barOnly    bool // aggregate mode 为 instead 时不写入原始数据
+	ordered    bool // 写入也按批次顺序进行
+
+	// Tables tag 文件中的子表，explode.table 生成的子表不在其中时作为错误行，不写入；为空时不检查
+	Tables map[string]struct{}
 
 	// aggregate
-	Total      atomic.Int64
-	ErrorCount atomic.Int64
-	Start      time.Time
-	End        time.Time
+	Total          atomic.Int64
+	ErrorCount     atomic.Int64
+	DuplicateCount atomic.Int64
+	FilteredCount  atomic.Int64
+	WrittenCount   atomic.Int64
+	BarCount       atomic.Int64
+	Start          time.Time
+	End            time.Time
 }
 
-func NewCsvImporter(conf config.Config, table string) (importer *CsvImporter, err error) {
-	conn, err := af.Open(conf.TDEngine.Host, conf.TDEngine.User, conf.TDEngine.Password, conf.DB.Name, conf.TDEngine.Port)
-	if err != nil {
-		return nil, err
-	}
+// line 源文件中的一行，num 从 1 开始，不含表头
+type line struct {
+	num  int
+	data map[string]any
+}
+
+// row 计算后待写入的一行，values 与 columns 一一对应，table 为空时写入 CsvImporter.table
+type row struct {
+	line   int
+	table  string
+	values []any
+}
+
+type batch struct {
+	seq   int
+	lines []line
+}
+
+// NewCsvImporter 导入一个文件，sink 由调用方创建，多个文件共用
+func NewCsvImporter(conf config.Config, table string, sink Sink) (importer *CsvImporter, err error) {
 	importer = &CsvImporter{
-		conn:       conn,
 		db:         conf.DB.Name,
 		table:      table,
 		columns:    conf.STable.Columns,
 		concurrent: conf.Concurrent,
 		batchSize:  conf.BatchSize,
+		filter:     conf.STable.Filter,
 	}
-	importer.extractor = field.NewExtractor(&importer.locker)
-	importer.precision = dbPrecision(conf.DB.Precision)
-	importer.insertSql = importer.stmtSql()
-	importer.columnTypes, err = importer.columnType()
+	if importer.from, err = timeBound(conf.STable.From, conf.DB.Precision); err != nil {
+		return importer, err
+	}
+	if importer.to, err = timeBound(conf.STable.To, conf.DB.Precision); err != nil {
+		return importer, err
+	}
+	importer.ordered = sink.ordered()
+	importer.extractor = field.NewExtractor()
+	importer.timeUnit = conf.DB.Precision
+	importer.tsIndex = timestampIndex(importer.columns)
+	importer.dedup = config.Dedup{Strategy: dedupStrategy(conf.STable), Window: conf.STable.Dedup.Window}
+	importer.dedups = make(map[string]*deduper)
+	if _, err = newDeduper(importer.dedup.Strategy, importer.dedup.Window, conf.DB.Precision); err != nil {
+		return importer, err
+	}
+	importer.sort = conf.STable.Sort
+	importer.sorters = make(map[string]*sorter)
+	if _, err = newSorter(importer.sort, importer.tsIndex); err != nil {
+		return importer, err
+	}
+	if importer.explode, err = newExploder(conf.STable.Explode); err != nil {
+		return importer, err
+	}
+	importer.naming = db_table.NamingPolicy{Case: conf.STable.Naming.Case, Replace: conf.STable.Naming.Replace, MaxLength: conf.STable.Naming.MaxLength}
+	writerTable := table
+	if importer.explode != nil && len(importer.explode.table) > 0 {
+		writerTable = ""
+	}
+	importer.writer, err = sink.writer(conf.DB.Name, writerTable, importer.columns, conf.DB.Precision)
+	if err != nil {
+		return importer, err
+	}
+
+	if importer.aggregate, err = newAggregator(conf.Aggregate, importer.columns); err != nil || importer.aggregate == nil {
+		return importer, err
+	}
+	importer.barOnly = conf.Aggregate.Mode == AggregateInstead
+	importer.barWriter, err = sink.writer(conf.DB.Name, "", AggregateColumns(conf.Aggregate), conf.DB.Precision)
 	return importer, err
 }
 
 func (c *CsvImporter) Import(ctx context.Context, csvPath string) (err error) {
-	defer func() {
-		_ = c.conn.Close()
-	}()
-
 	ch, err := common.ReadCsv(csvPath)
 	if err != nil {
 		return err
 	}
 	c.Start = time.Now()
 
+	batches := c.batch(ctx, ch)
 	var wait sync.WaitGroup
 	for i := 0; i < c.concurrent; i++ {
 		wait.Add(1)
-		go c.doImport(ctx, ch, &wait)
+		go c.doImport(ctx, batches, &wait)
 	}
 	wait.Wait()
+
+	// 所有批次处理完后输出排序缓存中的行和未输出的聚合窗口
+	rows, bars := c.sequenced(c.flushSorted())
+	if c.aggregate != nil {
+		bars = append(bars, c.aggregate.flush()...)
+		if c.aggregate.late > 0 {
+			log.Printf("## aggregate of table [%s] %d rows are later than output windows, not aggregated", c.table, c.aggregate.late)
+		}
+	}
+	c.do(ctx, rows)
+	c.writeBars(bars)
 	c.End = time.Now()
 
 	return
 }
 
-func (c *CsvImporter) doImport(ctx context.Context, ch chan map[string]string, wait *sync.WaitGroup) {
-	defer wait.Done()
-
-	tickerDuration := 100 * time.Millisecond
-	ticker := time.NewTicker(tickerDuration)
-	defer ticker.Stop()
+// batch 按文件顺序把行分批并编号
+func (c *CsvImporter) batch(ctx context.Context, ch chan map[string]string) chan batch {
+	batches := make(chan batch, c.concurrent)
 
-	lines := make([]map[string]any, 0, c.batchSize)
+	go func() {
+		defer close(batches)
+		seq, num := 0, 0
+		lines := make([]line, 0, c.batchSize)
 
-	for {
-		select {
-		case <-ctx.Done():
-			// todo
-		case data, ok := <-ch:
-			if !ok { // channel is closed
-				c.do(ctx, lines)
+		for {
+			select {
+			case <-ctx.Done():
 				return
-			}
+			case data, ok := <-ch:
+				if !ok { // channel is closed
+					if len(lines) > 0 {
+						batches <- batch{seq: seq, lines: lines}
+					}
+					return
+				}
 
-			lines = append(lines, common.StrMap2AnyMap(data))
-			if len(lines) >= c.batchSize {
-				c.do(ctx, lines)
-				lines = make([]map[string]any, 0, c.batchSize)
+				num++
+				lines = append(lines, line{num: num, data: common.StrMap2AnyMap(data)})
+				if len(lines) >= c.batchSize {
+					batches <- batch{seq: seq, lines: lines}
+					seq++
+					lines = make([]line, 0, c.batchSize)
+				}
 			}
-		case <-ticker.C:
-			c.do(ctx, lines)
-			lines = make([]map[string]any, 0, c.batchSize)
 		}
-	}
+	}()
+
+	return batches
 }
 
-func (c *CsvImporter) do(_ context.Context, lines []map[string]any) {
-	if len(lines) == 0 {
-		return
-	}
+func (c *CsvImporter) doImport(ctx context.Context, batches chan batch, wait *sync.WaitGroup) {
+	defer wait.Done()
 
-	stmt := c.conn.InsertStmt()
-	defer func() { _ = stmt.Close() }()
+	for b := range batches {
+		c.Total.Add(int64(len(b.lines)))
+		rows, filtered, errorRows := c.rows(b.lines)
+		c.ErrorCount.Add(int64(errorRows))
+		c.FilteredCount.Add(int64(filtered))
+
+		// 排序、时间戳去重和聚合必须按批次顺序进行，聚合结果和 ordered 时的原始数据也在其中写入
+		c.sequence.wait(b.seq)
+		var bars []row
+		rows, bars = c.sequenced(c.applySort(rows))
+		if c.ordered {
+			c.do(ctx, rows)
+		}
+		c.writeBars(bars)
+		c.sequence.done()
 
-	err := stmt.Prepare(c.insertSql)
-	if err != nil {
-		log.Printf("## prepare sql %s error %v", c.insertSql, err)
-		return
+		if !c.ordered {
+			c.do(ctx, rows)
+		}
 	}
+}
 
-	c.Total.Add(int64(len(lines)))
-	params, err := c.params(lines)
+// sequenced 对按顺序输出的行做去重和聚合，返回待写入的行和已结束的聚合窗口
+func (c *CsvImporter) sequenced(rows []row) ([]row, []row) {
+	rows = c.applyDedup(rows)
+	if c.aggregate == nil {
+		return rows, nil
+	}
+	return rows, c.aggregate.add(rows, c.table)
+}
 
-	if err != nil {
-		c.ErrorCount.Add(int64(len(lines)))
-		log.Println("## parse params error ", err)
-		return
+// applySort 按子表排序，返回可以输出的行
+func (c *CsvImporter) applySort(rows []row) []row {
+	if len(c.sort.Scope) == 0 {
+		return rows
 	}
 
-	if err = stmt.BindParam(params, c.columnTypes); err != nil {
-		c.ErrorCount.Add(int64(len(lines)))
-		log.Println("## bind params error ", c.table, err)
-		return
+	tables, groups := groupByTable(rows)
+	out := make([]row, 0, len(rows))
+	for _, table := range tables {
+		s, ok := c.sorters[table]
+		if !ok {
+			s, _ = newSorter(c.sort, c.tsIndex)
+			c.sorters[table] = s
+		}
+		out = append(out, s.add(groups[table])...)
 	}
-	if err = stmt.AddBatch(); err != nil {
-		c.ErrorCount.Add(int64(len(lines)))
-		log.Println("## add batch error ", err)
-		return
+	return out
+}
+
+// flushSorted 输出所有排序缓存中的行
+func (c *CsvImporter) flushSorted() []row {
+	tables := make([]string, 0, len(c.sorters))
+	for table := range c.sorters {
+		tables = append(tables, table)
 	}
-	if err = stmt.Execute(); err != nil {
-		c.ErrorCount.Add(int64(len(lines)))
-		log.Println("## insert data error ", err)
-		return
+	sort.Strings(tables)
+
+	var out []row
+	for _, table := range tables {
+		out = append(out, c.sorters[table].flush()...)
 	}
+	return out
 }
 
-func (c *CsvImporter) stmtSql() string {
-	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("insert into %s.%s values (", c.db, c.table))
-	for range c.columns {
-		buffer.WriteString("?, ")
+// applyDedup 按子表做时间戳去重
+func (c *CsvImporter) applyDedup(rows []row) []row {
+	if len(c.dedup.Strategy) == 0 || c.tsIndex < 0 {
+		return rows
 	}
-	buffer.Truncate(buffer.Len() - 2)
-	buffer.WriteString(")")
-	return buffer.String()
+
+	tables, groups := groupByTable(rows)
+	out := rows[:0]
+	for _, table := range tables {
+		d, ok := c.dedups[table]
+		if !ok {
+			d, _ = newDeduper(c.dedup.Strategy, c.dedup.Window, c.timeUnit)
+			d.table = table
+			if len(table) == 0 {
+				d.table = c.table
+			}
+			c.dedups[table] = d
+		}
+		group, duplicates := d.apply(groups[table], c.tsIndex)
+		c.DuplicateCount.Add(int64(duplicates))
+		out = append(out, group...)
+	}
+	return out
 }
 
-func (c *CsvImporter) columnType() (*param.ColumnType, error) {
-	columnType := param.NewColumnType(len(c.columns))
+// do 写入原始数据，aggregate mode 为 instead 时不写入。
+// 排序缓存输出、explode 展开时行数可能远超 batch_size，按 batch_size 分批写入
+func (c *CsvImporter) do(_ context.Context, rows []row) {
+	if c.barOnly {
+		return
+	}
 
-	for _, column := range c.columns {
-		if column.Type == common.TypeTimeStamp {
-			columnType.AddTimestamp()
-		}
-		if column.Type == common.TypeInt {
-			columnType.AddInt()
-		}
-		if column.Type == common.TypeIntUnSigned {
-			columnType.AddUInt()
-		}
-		if column.Type == common.TypeBigInt {
-			columnType.AddBigint()
-		}
-		if column.Type == common.TypeBigIntUnsigned {
-			columnType.AddUBigint()
+	for len(rows) > 0 {
+		n := len(rows)
+		if c.batchSize > 0 && n > c.batchSize {
+			n = c.batchSize
 		}
-		if column.Type == common.TypeFloat {
-			columnType.AddFloat()
+		if err := c.writer.write(rows[:n]); err != nil {
+			c.ErrorCount.Add(int64(n))
+			log.Printf("## write table [%s] error %v", c.table, err)
+		} else {
+			c.WrittenCount.Add(int64(n))
 		}
-		if column.Type == common.TypeDouble {
-			columnType.AddDouble()
-		}
-		if strings.HasPrefix(column.Type, common.TypeBinary) {
-			length, err := getFieldLength(column.Type, common.TypeBinary)
-			if err != nil {
-				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+		rows = rows[n:]
+	}
+}
+
+// writeBars 写入聚合结果，每个窗口只写入一次
+func (c *CsvImporter) writeBars(bars []row) {
+	if len(bars) == 0 {
+		return
+	}
+
+	if err := c.barWriter.write(bars); err != nil {
+		c.ErrorCount.Add(int64(len(bars)))
+		log.Printf("## write aggregate of table [%s] error %v", c.table, err)
+		return
+	}
+	c.BarCount.Add(int64(len(bars)))
+}
+
+// rows 计算每一行各列的值，时间戳列统一转为 time.Time。配置了 explode 时一行源数据会展开为多行。
+// 出错的行（包括 filter 出错）打印后跳过，不影响同一批的其他行。返回的 filtered 为被 filter 和 from/to 过滤掉的行数，
+// errorRows 为出错的行数，都按展开后的行计算，展开本身出错时按一行计算
+func (c *CsvImporter) rows(lines []line) (rows []row, filtered int, errorRows int) {
+	rows = make([]row, 0, len(lines))
+
+	for _, l := range lines {
+		indexes := []any{nil}
+		if c.explode != nil && len(c.explode.index) > 0 {
+			var err error
+			if indexes, err = c.explode.values(c.extractor, l.data); err != nil {
+				errorRows++
+				log.Printf("## parse params error line-[%d] %v", l.num, err)
+				continue
 			}
-			columnType.AddBinary(length)
-		}
-		if column.Type == common.TypeSmallInt {
-			columnType.AddSmallint()
-		}
-		if column.Type == common.TypeSmallIntUnSigned {
-			columnType.AddUSmallint()
-		}
-		if column.Type == common.TypeTinyInt {
-			columnType.AddTinyint()
-		}
-		if column.Type == common.TypeTinyIntUnsigned {
-			columnType.AddUTinyint()
 		}
-		if column.Type == common.TypeBool {
-			columnType.AddBool()
-		}
-		if strings.HasPrefix(column.Type, common.TypeNchar) {
-			length, err := getFieldLength(column.Type, common.TypeNchar)
-			if err != nil {
-				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+
+		for _, index := range indexes {
+			if c.explode != nil && len(c.explode.index) > 0 {
+				l.data[c.explode.index] = index
 			}
-			columnType.AddNchar(length)
-		}
-		if strings.HasPrefix(column.Type, common.TypeJson) {
-			length, err := getFieldLength(column.Type, common.TypeJson)
+
+			r, ok, err := c.row(l)
 			if err != nil {
-				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+				errorRows++
+				log.Println("## parse params error ", err)
+				continue
 			}
-			columnType.AddJson(length)
-		}
-		if strings.HasPrefix(column.Type, common.TypeVarchar) {
-			length, err := getFieldLength(column.Type, common.TypeVarchar)
-			if err != nil {
-				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+			if !ok {
+				filtered++
+				continue
 			}
-			columnType.AddBinary(length)
+			rows = append(rows, r)
 		}
 	}
 
-	return columnType, nil
+	return rows, filtered, errorRows
 }
 
-func (c *CsvImporter) params(lines []map[string]any) (params []*param.Param, err error) {
-	params = make([]*param.Param, 0, len(c.columns))
+// row 计算一行，被过滤时返回 false
+func (c *CsvImporter) row(l line) (row, bool, error) {
+	ok, err := c.match(l)
+	if err != nil || !ok {
+		return row{}, false, err
+	}
 
-	for _, column := range c.columns {
+	values := make([]any, len(c.columns))
+	for i, column := range c.columns {
 		source := column.Source
 		if len(source) == 0 {
-			return nil, fmt.Errorf("column-[%s] source is null", column.Field)
+			return row{}, false, fmt.Errorf("column-[%s] source is null", column.Field)
 		}
 
-		p := param.NewParam(len(lines))
-		for _, line := range lines {
-
-			value, err := c.extractor.Extract(source, line)
-			if err != nil {
-				return nil, err
-			}
-			if value == nil {
-				p.AddNull()
-				continue
+		value, err := c.extractor.Extract(source, l.data)
+		if err != nil {
+			return row{}, false, fmt.Errorf("line-[%d] column-[%s] %v", l.num, column.Field, err)
+		}
+		if value, err = column.Value(value); err != nil {
+			return row{}, false, fmt.Errorf("line-[%d] %v", l.num, err)
+		}
+		if value != nil && column.Type == common.TypeTimeStamp {
+			if value, err = common.Timestamp(value, c.timeUnit); err != nil {
+				return row{}, false, fmt.Errorf("line-[%d] column-[%s] %v", l.num, column.Field, err)
 			}
+		}
+		values[i] = value
+	}
 
-			if column.Type == common.TypeTimeStamp {
-				v, err := common.Time(value)
-				if err != nil {
-					return nil, err
-				}
-				p.AddTimestamp(v, c.precision)
-			}
+	if !c.inTimeRange(values) {
+		return row{}, false, nil
+	}
 
-			if column.Type == common.TypeInt || column.Type == common.TypeIntUnSigned || column.Type == common.TypeBigInt ||
-				column.Type == common.TypeBigIntUnsigned || column.Type == common.TypeSmallInt || column.Type == common.TypeSmallIntUnSigned ||
-				column.Type == common.TypeTinyInt || column.Type == common.TypeTinyIntUnsigned {
-				v, err := common.Int(value)
-				if err != nil {
-					return nil, err
-				}
-				switch column.Type {
-				case common.TypeInt:
-					p.AddInt(v)
-				case common.TypeIntUnSigned:
-					p.AddUInt(uint(v))
-				case common.TypeBigInt:
-					p.AddBigint(v)
-				case common.TypeBigIntUnsigned:
-					p.AddUBigint(uint(v))
-				case common.TypeSmallInt:
-					p.AddSmallint(v)
-				case common.TypeSmallIntUnSigned:
-					p.AddUSmallint(uint(v))
-				case common.TypeTinyInt:
-					p.AddTinyint(v)
-				case common.TypeTinyIntUnsigned:
-					p.AddUTinyint(uint(v))
-				}
-			}
+	r := row{line: l.num, values: values}
+	if c.explode != nil && len(c.explode.table) > 0 {
+		table, err := c.extractor.Extract(c.explode.table, l.data)
+		if err != nil {
+			return row{}, false, fmt.Errorf("line-[%d] explode table %v", l.num, err)
+		}
+		if r.table, err = c.naming.Normalize(common.String(table)); err != nil {
+			return row{}, false, fmt.Errorf("line-[%d] explode table %v", l.num, err)
+		}
+		if _, ok := c.Tables[r.table]; len(c.Tables) > 0 && !ok {
+			return row{}, false, fmt.Errorf("line-[%d] explode table [%s] is not in tag files", l.num, r.table)
+		}
+	}
+	return r, true, nil
+}
 
-			if column.Type == common.TypeFloat {
-				v, err := common.Float32(value)
-				if err != nil {
-					return nil, err
-				}
-				p.AddFloat(v)
-			}
-			if column.Type == common.TypeDouble {
-				v, err := common.Float64(value)
-				if err != nil {
-					return nil, err
-				}
-				p.AddDouble(v)
-			}
+// match 计算 filter 表达式，未配置 filter 时总是返回 true
+func (c *CsvImporter) match(l line) (bool, error) {
+	if len(c.filter) == 0 {
+		return true, nil
+	}
 
-			if column.Type == common.TypeBool {
-				v, err := common.Bool(value)
-				if err != nil {
-					return nil, err
-				}
-				p.AddBool(v)
-			}
+	value, err := c.extractor.Extract(c.filter, l.data)
+	if err != nil {
+		return false, fmt.Errorf("line-[%d] filter %v", l.num, err)
+	}
+	ok, err := common.Bool(value)
+	if err != nil {
+		return false, fmt.Errorf("line-[%d] filter result [%v] is not bool", l.num, value)
+	}
+	return ok, nil
+}
 
-			if strings.HasPrefix(column.Type, common.TypeBinary) {
-				p.AddBinary([]byte(common.String(value)))
-			}
-			if strings.HasPrefix(column.Type, common.TypeNchar) {
-				p.AddNchar(common.String(value))
-			}
-			if strings.HasPrefix(column.Type, common.TypeJson) {
-				p.AddJson([]byte(common.String(value)))
-			}
-			if strings.HasPrefix(column.Type, common.TypeVarchar) {
-				p.AddBinary([]byte(common.String(value)))
-			}
-		}
-		params = append(params, p)
+// inTimeRange 时间戳是否在 [from, to) 内
+func (c *CsvImporter) inTimeRange(values []any) bool {
+	if c.tsIndex < 0 || (c.from == nil && c.to == nil) {
+		return true
 	}
 
+	ts, ok := values[c.tsIndex].(time.Time)
+	if !ok {
+		return true
+	}
+	if c.from != nil && ts.Before(*c.from) {
+		return false
+	}
+	if c.to != nil && !ts.Before(*c.to) {
+		return false
+	}
+	return true
+}
+
+// groupByTable 按子表分组，tables 为子表第一次出现的顺序
+func groupByTable(rows []row) (tables []string, groups map[string][]row) {
+	groups = make(map[string][]row)
+	for _, r := range rows {
+		if _, ok := groups[r.table]; !ok {
+			tables = append(tables, r.table)
+		}
+		groups[r.table] = append(groups[r.table], r)
+	}
 	return
 }
 
-func getFieldLength(fieldType string, baseType string) (int, error) {
-	t := strings.Trim(fieldType, baseType)
-	t = strings.TrimLeft(t, "(")
-	t = strings.TrimRight(t, ")")
-	return strconv.Atoi(t)
+func timeBound(s string, precision string) (*time.Time, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := common.Timestamp(s, precision)
+	if err != nil {
+		return nil, fmt.Errorf("time bound [%s] error %v", s, err)
+	}
+	return &t, nil
+}
+
+// timestampIndex 返回第一个时间戳列的下标，没有则返回 -1
+func timestampIndex(columns []config.Column) int {
+	for i, column := range columns {
+		if column.Type == common.TypeTimeStamp {
+			return i
+		}
+	}
+	return -1
 }
 
-func dbPrecision(p string) int {
-	if p == "ns" {
-		return common2.PrecisionNanoSecond
+// dedupStrategy 未配置 dedup 但时间戳列使用了 avoid_datetime_conflict 的旧配置默认使用 bump
+func dedupStrategy(stable config.STable) string {
+	if len(stable.Dedup.Strategy) > 0 {
+		return stable.Dedup.Strategy
 	}
-	if p == "us" {
-		return common2.PrecisionMicroSecond
+	if i := timestampIndex(stable.Columns); i >= 0 && strings.Contains(stable.Columns[i].Source, "avoid_datetime_conflict(") {
+		return DedupBump
 	}
-	return common2.PrecisionMilliSecond
+	return ""
 }
@@ -2,8 +2,11 @@ package importer
 
 import (
 	"context"
+	"reflect"
 	"taos_importer/internal/config"
+	"taos_importer/internal/field"
 	"testing"
+	"time"
 )
 
 func TestCsvImporter_Import(t *testing.T) {
@@ -83,7 +86,12 @@ func TestCsvImporter_Import(t *testing.T) {
 		Concurrent: 10,
 		BatchSize:  100,
 	}
-	c, err := NewCsvImporter(conf, "t_900957")
+	pool, err := NewPool(conf)
+	if err != nil {
+		panic(err)
+	}
+	defer func() { _ = pool.Close() }()
+	c, err := NewCsvImporter(conf, "t_900957", pool)
 	if err != nil {
 		panic(err)
 	}
@@ -96,3 +104,195 @@ func TestCsvImporter_Import(t *testing.T) {
 	t.Log("## error ", c.ErrorCount.Load())
 	t.Log("## spend ", c.End.Sub(c.Start).Milliseconds())
 }
+
+func TestCsvImporter_Rows(t *testing.T) {
+	from := time.Date(2022, 11, 23, 9, 30, 0, 0, time.UTC)
+	to := time.Date(2022, 11, 23, 10, 0, 0, 0, time.UTC)
+	c := &CsvImporter{
+		columns: []config.Column{
+			{Field: "ts", Type: "timestamp", Source: "date_parse(date + left_pad(time, \"0\", 9), \"YYYYMMDDHHmmssSSS\")"},
+			{Field: "trade_volume", Type: "int", Source: "trade_volume"},
+		},
+		extractor: field.NewExtractor(),
+		filter:    "trade_volume > 0 && function_code != 67",
+		from:      &from,
+		to:        &to,
+	}
+
+	lines := []line{
+		{num: 1, data: map[string]any{"date": "20221123", "time": "94625100", "trade_volume": "100", "function_code": "70"}},
+		{num: 2, data: map[string]any{"date": "20221123", "time": "94625200", "trade_volume": "0", "function_code": "70"}},
+		{num: 3, data: map[string]any{"date": "20221123", "time": "94625300", "trade_volume": "100", "function_code": "67"}},
+		{num: 4, data: map[string]any{"date": "20221123", "time": "92500000", "trade_volume": "100", "function_code": "70"}},
+		{num: 5, data: map[string]any{"date": "20221123", "time": "100000000", "trade_volume": "100", "function_code": "70"}},
+	}
+	c.tsIndex = timestampIndex(c.columns)
+
+	rows, filtered, errorRows := c.rows(lines)
+	if errorRows != 0 {
+		t.Fatalf("expect no error, but got %d", errorRows)
+	}
+	if len(rows) != 1 || rows[0].line != 1 || filtered != 4 {
+		t.Fatalf("expect line 1 left and 4 filtered, but got %v %d", rows, filtered)
+	}
+}
+
+// filter 出错的行计为出错，不影响同一批的其他行
+func TestCsvImporter_RowsFilterError(t *testing.T) {
+	c := &CsvImporter{
+		columns:   []config.Column{{Field: "trade_volume", Type: "int", Source: "trade_volume"}},
+		extractor: field.NewExtractor(),
+		filter:    "flag",
+		tsIndex:   -1,
+	}
+
+	rows, filtered, errorRows := c.rows([]line{
+		{num: 1, data: map[string]any{"trade_volume": "100", "flag": "70"}},
+		{num: 2, data: map[string]any{"trade_volume": "0", "flag": "false"}},
+		{num: 3, data: map[string]any{"trade_volume": "100", "flag": "true"}},
+	})
+	if errorRows != 1 || filtered != 1 || len(rows) != 1 || rows[0].line != 3 {
+		t.Fatalf("expect line 1 error, line 2 filtered and line 3 left, but got %v %d %d", rows, filtered, errorRows)
+	}
+}
+
+func TestCsvImporter_RowsNull(t *testing.T) {
+	c := &CsvImporter{
+		columns: []config.Column{
+			{Field: "price", Type: "int", Source: "price"},
+			{Field: "volume", Type: "int", Source: "volume", NullValues: []string{"", "NULL", "\\N", "-"}, Default: int64(0)},
+			{Field: "name", Type: "varchar(10)", Source: "name", NullValues: []string{"NULL"}},
+			{Field: "code", Type: "int", Source: "code", NotNull: true},
+		},
+		extractor: field.NewExtractor(),
+		tsIndex:   -1,
+	}
+
+	rows, _, errorRows := c.rows([]line{{num: 1, data: map[string]any{"price": "", "volume": "\\N", "name": "", "code": "1"}}})
+	if errorRows != 0 {
+		t.Fatalf("expect no error, but got %d", errorRows)
+	}
+	if expect := []any{nil, int64(0), "", "1"}; !reflect.DeepEqual(rows[0].values, expect) {
+		t.Fatalf("expect-[%v] but got-[%v]", expect, rows[0].values)
+	}
+
+	// 出错的行不影响同一批的其他行
+	rows, _, errorRows = c.rows([]line{
+		{num: 2, data: map[string]any{"price": "1", "volume": "1", "name": "a", "code": ""}},
+		{num: 3, data: map[string]any{"price": "1", "volume": "1", "name": "a", "code": "3"}},
+	})
+	if errorRows != 1 || len(rows) != 1 || rows[0].line != 3 {
+		t.Fatalf("not null column should fail, but got %v %d", rows, errorRows)
+	}
+}
+
+func TestCsvImporter_RowsExplode(t *testing.T) {
+	cases := []struct {
+		name    string
+		explode config.Explode
+		tables  map[string]struct{}
+		expect  []row
+		errors  int
+	}{
+		{
+			name:    "ranges",
+			explode: config.Explode{Index: "level", Ranges: []string{"1-2", "5"}},
+			expect: []row{
+				{line: 1, values: []any{int64(1), "10.1"}},
+				{line: 1, values: []any{int64(2), "10.2"}},
+				{line: 1, values: []any{int64(5), nil}},
+			},
+		},
+		{
+			name:    "expression and table",
+			explode: config.Explode{Index: "level", Expression: "seq(1, 2)", Table: "\"t_depth_\" + code + \"_\" + level"},
+			expect: []row{
+				{line: 1, table: "t_depth_600000_1", values: []any{int64(1), "10.1"}},
+				{line: 1, table: "t_depth_600000_2", values: []any{int64(2), "10.2"}},
+			},
+		},
+		{
+			name:    "table not in tag files",
+			explode: config.Explode{Index: "level", Expression: "seq(1, 2)", Table: "\"T_Depth_\" + code + \"_\" + level"},
+			tables:  map[string]struct{}{"t_depth_600000_2": {}},
+			expect:  []row{{line: 1, table: "t_depth_600000_2", values: []any{int64(2), "10.2"}}},
+			errors:  1,
+		},
+		{
+			name:    "explode error",
+			explode: config.Explode{Index: "level", Expression: "seq(1)"},
+			errors:  1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e, err := newExploder(c.explode)
+			if err != nil {
+				t.Fatal(err)
+			}
+			importer := &CsvImporter{
+				columns: []config.Column{
+					{Field: "level", Type: "int", Source: "level"},
+					{Field: "bid_price", Type: "double", Source: "col(\"bid_price\" + level)"},
+				},
+				extractor: field.NewExtractor(),
+				explode:   e,
+				tsIndex:   -1,
+				Tables:    c.tables,
+			}
+
+			data := map[string]any{"code": "600000", "bid_price1": "10.1", "bid_price2": "10.2"}
+			rows, _, errorRows := importer.rows([]line{{num: 1, data: data}})
+			if errorRows != c.errors {
+				t.Fatalf("expect %d errors, but got %d", c.errors, errorRows)
+			}
+			if len(rows) != len(c.expect) {
+				t.Fatalf("expect %d rows, but got %d", len(c.expect), len(rows))
+			}
+			for i, r := range rows {
+				if r.line != c.expect[i].line || r.table != c.expect[i].table ||
+					r.values[0] != c.expect[i].values[0] || r.values[1] != c.expect[i].values[1] {
+					t.Fatalf("row %d expect-[%v], but got-[%v]", i, c.expect[i], r)
+				}
+			}
+		})
+	}
+}
+
+// batchWriter 记录每次写入的行数
+type batchWriter struct {
+	batches []int
+}
+
+func (w *batchWriter) write(rows []row) error {
+	w.batches = append(w.batches, len(rows))
+	return nil
+}
+
+func TestCsvImporter_DoBatch(t *testing.T) {
+	cases := []struct {
+		name      string
+		batchSize int
+		rows      int
+		expect    []int
+	}{
+		{name: "sorted file", batchSize: 2, rows: 5, expect: []int{2, 2, 1}},
+		{name: "one batch", batchSize: 10, rows: 5, expect: []int{5}},
+		{name: "empty", batchSize: 2},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := &batchWriter{}
+			importer := &CsvImporter{batchSize: c.batchSize, writer: w}
+			importer.do(context.Background(), make([]row, c.rows))
+			if !reflect.DeepEqual(w.batches, c.expect) {
+				t.Fatalf("expect-%v but got-%v", c.expect, w.batches)
+			}
+			if importer.WrittenCount.Load() != int64(c.rows) {
+				t.Fatalf("written error. expect-[%d] but got-[%d]", c.rows, importer.WrittenCount.Load())
+			}
+		})
+	}
+}
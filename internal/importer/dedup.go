@@ -0,0 +1,130 @@
+package importer
+
+import (
+	"fmt"
+	"log"
+	"taos_importer/internal/common"
+	"time"
+)
+
+const (
+	DedupBump     = "bump"      // 时间戳冲突时按精度单位顺延，直到不冲突
+	DedupDrop     = "drop"      // 丢弃后出现的重复行
+	DedupKeepLast = "keep_last" // 保留最后出现的行
+	DedupReport   = "report"    // 不处理，只记录重复的行，由 TDengine 按时间戳覆盖
+
+	defaultDedupWindow = 10 * time.Minute
+	maxDedupKeys       = 1 << 20
+)
+
+// deduper 处理同一子表内的时间戳冲突。
+// 调用方必须按文件顺序调用 apply，结果只和行的顺序有关，与 goroutine 调度无关。
+// 只记录最近 window 内（按已见到的最大时间戳计算）以及最多 maxDedupKeys 个时间戳，超出部分不再参与冲突判断。
+type deduper struct {
+	table    string // 只用于日志
+	strategy string
+	unit     time.Duration
+	window   int64
+	used     map[int64]struct{}
+	keys     []int64 // 按加入顺序记录 used 中的 key，用于淘汰
+	max      int64
+}
+
+func newDeduper(strategy string, window string, precision string) (*deduper, error) {
+	if len(strategy) == 0 {
+		return nil, nil
+	}
+	if strategy != DedupBump && strategy != DedupDrop && strategy != DedupKeepLast && strategy != DedupReport {
+		return nil, fmt.Errorf("unknown dedup strategy [%s]", strategy)
+	}
+
+	d := &deduper{strategy: strategy, unit: time.Millisecond, used: make(map[int64]struct{})}
+	if precision == common.PrecisionMicroSecond {
+		d.unit = time.Microsecond
+	} else if precision == common.PrecisionNanoSecond {
+		d.unit = time.Nanosecond
+	}
+
+	w := defaultDedupWindow
+	if len(window) > 0 {
+		var err error
+		if w, err = common.ParseDuration(window); err != nil {
+			return nil, err
+		}
+	}
+	d.window = int64(w / d.unit)
+
+	return d, nil
+}
+
+// apply 对一批行做时间戳去重，index 为时间戳所在列，返回处理后的行以及被丢弃或覆盖的行数
+func (d *deduper) apply(rows []row, index int) ([]row, int) {
+	out := rows[:0]
+	duplicates := 0
+	positions := make(map[int64]int, len(rows)) // keep_last: 本批中时间戳对应的 out 下标
+
+	for _, r := range rows {
+		ts, ok := r.values[index].(time.Time)
+		if !ok {
+			out = append(out, r)
+			continue
+		}
+
+		key := ts.UnixNano() / int64(d.unit)
+		if _, exist := d.used[key]; exist {
+			switch d.strategy {
+			case DedupBump:
+				for exist {
+					key++
+					ts = ts.Add(d.unit)
+					_, exist = d.used[key]
+				}
+				r.values[index] = ts
+			case DedupDrop:
+				duplicates++
+				continue
+			case DedupKeepLast:
+				duplicates++
+				if i, ok := positions[key]; ok {
+					out[i] = r
+					continue
+				}
+				// 之前批次已写入的行由 TDengine 按时间戳覆盖
+			case DedupReport:
+				duplicates++
+				log.Printf("## table [%s] line-[%d] duplicate timestamp [%s]", d.table, r.line, ts.Format(time.RFC3339Nano))
+			}
+		}
+
+		d.add(key)
+		positions[key] = len(out)
+		out = append(out, r)
+	}
+
+	d.evict()
+	return out, duplicates
+}
+
+func (d *deduper) add(key int64) {
+	if _, exist := d.used[key]; exist {
+		return
+	}
+	d.used[key] = struct{}{}
+	d.keys = append(d.keys, key)
+	if key > d.max {
+		d.max = key
+	}
+}
+
+func (d *deduper) evict() {
+	i := 0
+	for ; i < len(d.keys); i++ {
+		if d.keys[i] >= d.max-d.window && len(d.keys)-i <= maxDedupKeys {
+			break
+		}
+		delete(d.used, d.keys[i])
+	}
+	if i > 0 {
+		d.keys = append(d.keys[:0], d.keys[i:]...)
+	}
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeduper_Apply(t *testing.T) {
+	base := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	ms := func(n int) time.Time { return base.Add(time.Duration(n) * time.Millisecond) }
+
+	cases := []struct {
+		name       string
+		strategy   string
+		batches    [][]time.Time
+		expect     []time.Time
+		duplicates int
+	}{
+		{
+			name:     "bump",
+			strategy: DedupBump,
+			batches: [][]time.Time{
+				{ms(0), ms(0), ms(1)},
+				{ms(0), ms(5)},
+			},
+			expect: []time.Time{ms(0), ms(1), ms(2), ms(3), ms(5)},
+		},
+		{
+			name:     "drop",
+			strategy: DedupDrop,
+			batches: [][]time.Time{
+				{ms(0), ms(0), ms(1)},
+				{ms(1), ms(5)},
+			},
+			expect:     []time.Time{ms(0), ms(1), ms(5)},
+			duplicates: 2,
+		},
+		{
+			name:     "keep_last",
+			strategy: DedupKeepLast,
+			batches: [][]time.Time{
+				{ms(0), ms(0), ms(1)},
+			},
+			expect:     []time.Time{ms(0), ms(1)},
+			duplicates: 1,
+		},
+		{
+			name:     "report",
+			strategy: DedupReport,
+			batches: [][]time.Time{
+				{ms(0), ms(0), ms(1)},
+				{ms(1)},
+			},
+			expect:     []time.Time{ms(0), ms(0), ms(1), ms(1)},
+			duplicates: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			d, err := newDeduper(c.strategy, "", "ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var res []row
+			duplicates := 0
+			num := 0
+			for _, b := range c.batches {
+				rows := make([]row, 0, len(b))
+				for _, ts := range b {
+					num++
+					rows = append(rows, row{line: num, values: []any{ts, num}})
+				}
+				out, dup := d.apply(rows, 0)
+				res = append(res, out...)
+				duplicates += dup
+			}
+
+			if len(res) != len(c.expect) || duplicates != c.duplicates {
+				t.Fatalf("expect %d rows %d duplicates, but got %d rows %d duplicates", len(c.expect), c.duplicates, len(res), duplicates)
+			}
+			for i, r := range res {
+				if !r.values[0].(time.Time).Equal(c.expect[i]) {
+					t.Fatalf("row %d expect-[%v], but got-[%v]", i, c.expect[i], r.values[0])
+				}
+			}
+		})
+	}
+}
+
+func TestDeduper_KeepLastValue(t *testing.T) {
+	d, err := newDeduper(DedupKeepLast, "", "ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	out, _ := d.apply([]row{{line: 1, values: []any{ts, "a"}}, {line: 2, values: []any{ts, "b"}}}, 0)
+	if len(out) != 1 || out[0].values[1] != "b" {
+		t.Fatalf("keep_last should keep the last row, but got %v", out)
+	}
+}
+
+func TestDeduper_Window(t *testing.T) {
+	d, err := newDeduper(DedupDrop, "10ms", "ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	d.apply([]row{{values: []any{base}}, {values: []any{base.Add(time.Second)}}}, 0)
+	if len(d.used) != 1 {
+		t.Fatalf("timestamps out of window should be evicted, but got %d", len(d.used))
+	}
+}
+
+func TestNewDeduper(t *testing.T) {
+	if d, err := newDeduper("", "", "ms"); d != nil || err != nil {
+		t.Fatal("empty strategy should disable dedup")
+	}
+	if _, err := newDeduper("unknown", "", "ms"); err == nil {
+		t.Fatal("unknown strategy should fail")
+	}
+}
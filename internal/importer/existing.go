@@ -0,0 +1,186 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"time"
+)
+
+const (
+	OnExistingAppend       = "append"        // 直接写入，时间戳相同的行被覆盖
+	OnExistingSkipTable    = "skip_table"    // 子表在文件的时间范围内已有数据时不写入这个子表
+	OnExistingReplaceRange = "replace_range" // 写入前删除子表在文件的时间范围内的数据
+	OnExistingReplaceTable = "replace_table" // 写入前删除子表所有的数据，每次导入每个子表只删除一次
+)
+
+// ExistingTarget 查询和删除子表已有的数据，DatabaseAndTable 实现
+type ExistingTarget interface {
+	TableSummary(ctx context.Context, param db_table.SummaryParam) (db_table.Summary, error)
+	DeleteRows(ctx context.Context, param db_table.DeleteParam) error
+}
+
+// Existing 导入文件前按 on_existing 处理子表已有的数据。
+// 先读取一遍文件，只计算时间戳列（和 explode.table），得到文件写入的每个子表及其时间范围。
+// replace_range、replace_table 在导入前删除数据，导入失败时删除的数据不会恢复
+type Existing struct {
+	conf     config.Config
+	policy   string
+	target   ExistingTarget
+	mu       sync.Mutex
+	replaced map[string]struct{} // replace_table 时已删除数据的子表
+}
+
+func NewExisting(conf config.Config, target ExistingTarget) (*Existing, error) {
+	policy := conf.STable.OnExisting
+	if len(policy) == 0 {
+		policy = OnExistingAppend
+	}
+	if policy != OnExistingAppend && policy != OnExistingSkipTable && policy != OnExistingReplaceRange && policy != OnExistingReplaceTable {
+		return nil, fmt.Errorf("unknown on_existing [%s]", policy)
+	}
+	return &Existing{conf: conf, policy: policy, target: target, replaced: make(map[string]struct{})}, nil
+}
+
+// Policy 返回 on_existing，未配置时为 append
+func (e *Existing) Policy() string {
+	return e.policy
+}
+
+// Prepare 处理文件写入的子表已有的数据，返回写入使用的 sink 和每个子表的处理结果。
+// 文件写入的子表都被跳过时返回的 sink 为 nil
+func (e *Existing) Prepare(ctx context.Context, table string, file string, sink Sink) (Sink, []string, error) {
+	if e.policy == OnExistingAppend {
+		return sink, nil, nil
+	}
+
+	stats := NewStats(nil)
+	scanner, err := NewCsvImporter(scanConfig(e.conf), table, stats)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = scanner.Import(ctx, file); err != nil {
+		return nil, nil, err
+	}
+
+	tables := stats.Tables()
+	var messages []string
+	skip := make(map[string]struct{})
+	for _, t := range tables {
+		var msg string
+		switch e.policy {
+		case OnExistingSkipTable:
+			var summary db_table.Summary
+			summary, err = e.target.TableSummary(ctx, db_table.SummaryParam{DBName: t.DB, TableName: t.Table, TsColumn: t.TsColumn, From: t.First, To: t.Last})
+			if err == nil && summary.Rows > 0 {
+				skip[t.Table] = struct{}{}
+				msg = fmt.Sprintf("## skip table [%s], it has rows-[%d] in [%s, %s]", t.Table, summary.Rows, format(t.First), format(t.Last))
+			}
+		case OnExistingReplaceRange:
+			err = e.target.DeleteRows(ctx, db_table.DeleteParam{DBName: t.DB, TableName: t.Table, TsColumn: t.TsColumn, From: &t.First, To: &t.Last})
+			msg = fmt.Sprintf("## delete rows of table [%s] in [%s, %s]", t.Table, format(t.First), format(t.Last))
+		case OnExistingReplaceTable:
+			msg, err = e.replaceTable(ctx, t)
+		}
+		if err != nil {
+			return nil, messages, err
+		}
+		if len(msg) > 0 {
+			messages = append(messages, msg)
+		}
+	}
+
+	if len(skip) == 0 {
+		return sink, messages, nil
+	}
+	if len(skip) == len(tables) {
+		return nil, messages, nil
+	}
+	return SkipTables(sink, skip), messages, nil
+}
+
+// scanConfig 只计算时间戳列，不做 filter、排序、聚合，去重只保留会改变时间戳的 bump。
+// 得到的时间范围包含被 filter 过滤掉的行
+func scanConfig(conf config.Config) config.Config {
+	i := timestampIndex(conf.STable.Columns)
+	if i < 0 {
+		return conf
+	}
+	stable := conf.STable
+	stable.Columns = []config.Column{stable.Columns[i]}
+	stable.Filter = ""
+	stable.Sort = config.Sort{}
+	stable.Dedup = config.Dedup{Window: stable.Dedup.Window}
+	if strategy := dedupStrategy(conf.STable); strategy == DedupBump {
+		stable.Dedup.Strategy = strategy
+	}
+	conf.STable = stable
+	conf.Aggregate = config.Aggregate{}
+	return conf
+}
+
+func (e *Existing) replaceTable(ctx context.Context, t TableStats) (string, error) {
+	key := t.DB + "." + t.Table
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.replaced[key]; ok {
+		return "", nil
+	}
+	if err := e.target.DeleteRows(ctx, db_table.DeleteParam{DBName: t.DB, TableName: t.Table}); err != nil {
+		return "", err
+	}
+	e.replaced[key] = struct{}{}
+	return fmt.Sprintf("## delete all rows of table [%s]", t.Table), nil
+}
+
+func format(t time.Time) string {
+	return t.Format(time.RFC3339Nano)
+}
+
+// SkipTables 不写入 tables 中的子表，其他子表写入 sink
+func SkipTables(sink Sink, tables map[string]struct{}) Sink {
+	return &skipSink{Sink: sink, tables: tables}
+}
+
+type skipSink struct {
+	Sink
+	tables map[string]struct{}
+}
+
+func (s *skipSink) writer(db string, table string, columns []config.Column, precision string) (rowWriter, error) {
+	w, err := s.Sink.writer(db, table, columns, precision)
+	if err != nil {
+		return nil, err
+	}
+	return &skipWriter{rowWriter: w, table: table, tables: s.tables}, nil
+}
+
+// Close sink 由创建方关闭
+func (s *skipSink) Close() error {
+	return nil
+}
+
+type skipWriter struct {
+	rowWriter
+	table  string
+	tables map[string]struct{}
+}
+
+func (w *skipWriter) write(rows []row) error {
+	kept := make([]row, 0, len(rows))
+	for _, r := range rows {
+		name := w.table
+		if len(name) == 0 {
+			name = r.table
+		}
+		if _, ok := w.tables[name]; !ok {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return w.rowWriter.write(kept)
+}
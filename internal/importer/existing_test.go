@@ -0,0 +1,202 @@
+package importer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"testing"
+	"time"
+)
+
+// fakeExisting rows 为子表已有的行数，记录删除的参数
+type fakeExisting struct {
+	rows    map[string]int64
+	queries int
+	deletes []db_table.DeleteParam
+}
+
+func (f *fakeExisting) TableSummary(_ context.Context, param db_table.SummaryParam) (db_table.Summary, error) {
+	f.queries++
+	return db_table.Summary{Rows: f.rows[param.TableName]}, nil
+}
+
+func (f *fakeExisting) DeleteRows(_ context.Context, param db_table.DeleteParam) error {
+	f.deletes = append(f.deletes, param)
+	return nil
+}
+
+func TestExisting_Prepare(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "1.csv")
+	data := "ts,price\n1669167985000,1\n1669167987000,3\n1669167986000,2\n"
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf := config.Config{
+		DB:          config.Database{Name: "stock", Precision: "ms"},
+		BatchSize:   2,
+		Concurrent:  2,
+		DealOneTime: 1,
+		STable: config.STable{
+			Columns: []config.Column{
+				{Field: "ts", Type: "timestamp", Source: "ts"},
+				{Field: "price", Type: "double", Source: "price"},
+			},
+		},
+	}
+	first := time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC)
+	last := first.Add(2 * time.Second)
+
+	cases := []struct {
+		name     string
+		policy   string
+		rows     map[string]int64
+		files    int // 导入的次数
+		skipped  bool
+		queries  int
+		deletes  []db_table.DeleteParam
+		messages int
+	}{
+		{name: "default", files: 1},
+		{name: "append", policy: OnExistingAppend, files: 2},
+		{name: "skip table", policy: OnExistingSkipTable, rows: map[string]int64{"t_1": 3}, files: 1, skipped: true, queries: 1, messages: 1},
+		{name: "no existing rows", policy: OnExistingSkipTable, files: 2, queries: 2},
+		{
+			name:   "replace range",
+			policy: OnExistingReplaceRange,
+			files:  2,
+			deletes: []db_table.DeleteParam{
+				{DBName: "stock", TableName: "t_1", TsColumn: "ts", From: &first, To: &last},
+				{DBName: "stock", TableName: "t_1", TsColumn: "ts", From: &first, To: &last},
+			},
+			messages: 2,
+		},
+		{
+			name:     "replace table once",
+			policy:   OnExistingReplaceTable,
+			files:    2,
+			deletes:  []db_table.DeleteParam{{DBName: "stock", TableName: "t_1"}},
+			messages: 1,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf.STable.OnExisting = c.policy
+			target := &fakeExisting{rows: c.rows}
+			e, err := NewExisting(conf, target)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sink := NewStats(nil)
+			messages := 0
+			for i := 0; i < c.files; i++ {
+				s, msgs, err := e.Prepare(context.Background(), "t_1", file, sink)
+				if err != nil {
+					t.Fatal(err)
+				}
+				messages += len(msgs)
+				if (s == nil) != c.skipped {
+					t.Errorf("sink %v, expect skipped %v", s, c.skipped)
+				}
+			}
+			if target.queries != c.queries || messages != c.messages {
+				t.Errorf("queries %d messages %d", target.queries, messages)
+			}
+			if len(target.deletes) != len(c.deletes) {
+				t.Fatalf("deletes %+v", target.deletes)
+			}
+			for i, d := range target.deletes {
+				expect := c.deletes[i]
+				if d.DBName != expect.DBName || d.TableName != expect.TableName || d.TsColumn != expect.TsColumn ||
+					(d.From == nil) != (expect.From == nil) || (d.From != nil && (!d.From.Equal(*expect.From) || !d.To.Equal(*expect.To))) {
+					t.Errorf("delete %+v, expect %+v", d, expect)
+				}
+			}
+		})
+	}
+
+	conf.STable.OnExisting = "overwrite"
+	if _, err := NewExisting(conf, &fakeExisting{}); err == nil {
+		t.Error("expect error of unknown on_existing")
+	}
+}
+
+func TestScanConfig(t *testing.T) {
+	columns := []config.Column{
+		{Field: "price", Type: "double", Source: "lookup(\"price\", code)"},
+		{Field: "ts", Type: "timestamp", Source: "ts"},
+	}
+	cases := []struct {
+		name  string
+		dedup config.Dedup
+		ts    string
+		want  string // 扫描时的 dedup strategy
+	}{
+		{name: "report", dedup: config.Dedup{Strategy: DedupReport}},
+		{name: "bump", dedup: config.Dedup{Strategy: DedupBump, Window: "1m"}, want: DedupBump},
+		{name: "legacy bump", ts: "avoid_datetime_conflict(ts, 1, \"ms\")", want: DedupBump},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			conf := config.Config{
+				STable: config.STable{
+					Columns: append([]config.Column(nil), columns...),
+					Filter:  "price > 0",
+					Dedup:   c.dedup,
+					Sort:    config.Sort{Scope: SortFile},
+				},
+				Aggregate: config.Aggregate{STable: "kline", Window: "1m"},
+			}
+			if len(c.ts) > 0 {
+				conf.STable.Columns[1].Source = c.ts
+			}
+			scan := scanConfig(conf).STable
+			if len(scan.Columns) != 1 || scan.Columns[0].Field != "ts" || len(scan.Filter) > 0 || len(scan.Sort.Scope) > 0 {
+				t.Fatalf("scan config error %+v", scan)
+			}
+			if scan.Dedup.Strategy != c.want || scan.Dedup.Window != c.dedup.Window {
+				t.Fatalf("dedup error. expect-[%s] but got-%+v", c.want, scan.Dedup)
+			}
+			if len(conf.STable.Columns) != 2 {
+				t.Fatal("columns of config are changed")
+			}
+		})
+	}
+}
+
+func TestSkipTables(t *testing.T) {
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}}
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{"1669167985000"}},
+		{line: 2, table: "t_2", values: []any{"1669167985000"}},
+	}
+	stats := NewStats(nil)
+	sink := SkipTables(stats, map[string]struct{}{"t_2": {}})
+
+	w, err := sink.writer("stock", "", columns, "ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.write(rows); err != nil {
+		t.Fatal(err)
+	}
+	// 写入的子表被跳过时不写入任何行
+	if w, err = sink.writer("stock", "t_2", columns, "ms"); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.write(rows[:1]); err != nil {
+		t.Fatal(err)
+	}
+
+	var tables []string
+	for _, s := range stats.Tables() {
+		tables = append(tables, s.Table)
+	}
+	if !reflect.DeepEqual(tables, []string{"t_1"}) || !sink.ordered() {
+		t.Errorf("tables %v", tables)
+	}
+}
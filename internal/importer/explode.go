@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"taos_importer/internal/config"
+	"taos_importer/internal/field"
+)
+
+const defaultExplodeIndex = "i"
+
+// exploder 把一行源数据展开为多行，每行计算前把下标写入 index 变量
+type exploder struct {
+	index      string
+	indexes    []any // 由 ranges 展开的下标
+	expression string
+	table      string // 子表名表达式，为空则写入文件对应的子表
+}
+
+func newExploder(conf config.Explode) (*exploder, error) {
+	if len(conf.Ranges) == 0 && len(conf.Expression) == 0 {
+		if len(conf.Table) > 0 {
+			return &exploder{table: conf.Table}, nil
+		}
+		return nil, nil
+	}
+	if len(conf.Ranges) > 0 && len(conf.Expression) > 0 {
+		return nil, fmt.Errorf("explode ranges and expression are mutually exclusive")
+	}
+
+	e := &exploder{index: conf.Index, expression: conf.Expression, table: conf.Table}
+	if len(e.index) == 0 {
+		e.index = defaultExplodeIndex
+	}
+	for _, r := range conf.Ranges {
+		start, end, err := parseRange(r)
+		if err != nil {
+			return nil, err
+		}
+		for i := start; i <= end; i++ {
+			e.indexes = append(e.indexes, i)
+		}
+	}
+	return e, nil
+}
+
+// values 返回 data 要展开的下标，nil 表示不展开
+func (e *exploder) values(extractor *field.Extractor, data map[string]any) ([]any, error) {
+	if len(e.expression) == 0 {
+		return e.indexes, nil
+	}
+
+	v, err := extractor.Extract(e.expression, data)
+	if err != nil {
+		return nil, fmt.Errorf("explode %v", err)
+	}
+	switch v := v.(type) {
+	case nil:
+		return []any{}, nil
+	case []any:
+		return v, nil
+	case []string:
+		values := make([]any, 0, len(v))
+		for _, s := range v {
+			values = append(values, s)
+		}
+		return values, nil
+	default:
+		return []any{v}, nil
+	}
+}
+
+// parseRange 解析 "1-10" 或 "5"，两端都包含
+func parseRange(r string) (int64, int64, error) {
+	s, e, ok := strings.Cut(strings.TrimSpace(r), "-")
+	start, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("explode range [%s] error %v", r, err)
+	}
+	if !ok {
+		return start, start, nil
+	}
+	end, err := strconv.ParseInt(strings.TrimSpace(e), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("explode range [%s] error %v", r, err)
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("explode range [%s] error, end is less than start", r)
+	}
+	return start, end, nil
+}
@@ -0,0 +1,123 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"time"
+)
+
+// MirrorSource 查询源超级表的子表和 tag，DatabaseAndTable 实现
+type MirrorSource interface {
+	ExistingTables(ctx context.Context, db string, stable string) (map[string]map[string]string, error)
+}
+
+// MirrorTarget 在目标库中创建子表，DatabaseAndTable 实现
+type MirrorTarget interface {
+	CreateTables(ctx context.Context, params []db_table.TableParam) error
+}
+
+// Mirror 消费源超级表 [stable] 列的主题，按相同的列写入 targetDB 中的同名超级表。
+// 第一次写入一个子表前按源子表的 tag 在目标库中创建
+type Mirror struct {
+	*Rates
+	conf     config.Config
+	targetDB string
+	consumer Consumer
+	source   MirrorSource
+	target   MirrorTarget
+	writer   rowWriter
+	tags     map[string]map[string]string // 源子表的 tag
+	created  map[string]struct{}
+
+	Tables atomic.Int64 // 创建的子表数
+	Rows   atomic.Int64
+}
+
+func NewMirror(conf config.Config, targetDB string, consumer Consumer, source MirrorSource, target MirrorTarget, sink Sink, start time.Time) (*Mirror, error) {
+	if len(targetDB) == 0 || targetDB == conf.DB.Name {
+		return nil, fmt.Errorf("target db [%s] must be different from db [%s]", targetDB, conf.DB.Name)
+	}
+	w, err := sink.writer(targetDB, "", conf.STable.Columns, conf.DB.Precision)
+	if err != nil {
+		return nil, err
+	}
+	return &Mirror{
+		Rates:    NewRates(start),
+		conf:     conf,
+		targetDB: targetDB,
+		consumer: consumer,
+		source:   source,
+		target:   target,
+		writer:   w,
+		created:  make(map[string]struct{}),
+	}, nil
+}
+
+// Poll 消费一次，写入成功后提交，返回写入的行数。出错时不提交，重新订阅后再次消费
+func (m *Mirror) Poll(ctx context.Context, timeout time.Duration) (int, error) {
+	message, err := m.consumer.Poll(timeout)
+	if err != nil || message == nil {
+		return 0, err
+	}
+	var rows []row
+	for _, table := range message.Tables {
+		if err = m.createTable(ctx, table.Table); err != nil {
+			return 0, err
+		}
+		for i, values := range table.Rows {
+			if len(values) != len(m.conf.STable.Columns) {
+				return 0, fmt.Errorf("table [%s] row-[%d] has %d values, expect %d", table.Table, i+1, len(values), len(m.conf.STable.Columns))
+			}
+			rows = append(rows, row{line: i + 1, table: table.Table, values: values})
+		}
+	}
+	if len(rows) > 0 {
+		if err = m.writer.write(rows); err != nil {
+			return 0, err
+		}
+	}
+	if err = m.consumer.Commit(message); err != nil {
+		return 0, err
+	}
+	for _, table := range message.Tables {
+		m.add(table.Table, len(table.Rows))
+	}
+	m.Rows.Add(int64(len(rows)))
+	return len(rows), nil
+}
+
+// createTable 子表不在已查询的源子表中时重新查询，源子表不存在时返回 error
+func (m *Mirror) createTable(ctx context.Context, table string) error {
+	if _, ok := m.created[table]; ok {
+		return nil
+	}
+	stored, ok := m.tags[table]
+	if !ok {
+		tags, err := m.source.ExistingTables(ctx, m.conf.DB.Name, m.conf.STable.Name)
+		if err != nil {
+			return err
+		}
+		m.tags = tags
+		if stored, ok = tags[table]; !ok {
+			return fmt.Errorf("table [%s] not found in stable [%s]", table, m.conf.STable.Name)
+		}
+	}
+
+	param := db_table.TableParam{DBName: m.targetDB, STableName: m.conf.STable.Name, TableName: table, Precision: m.conf.DB.Precision}
+	for _, tag := range m.conf.STable.Tags {
+		tv := db_table.TagValue{TagName: tag.Field, TagValueType: tag.Type}
+		if v, ok := stored[tag.Field]; ok {
+			tv.TagValue = v
+		}
+		param.TagValues = append(param.TagValues, tv)
+	}
+	if err := m.target.CreateTables(ctx, []db_table.TableParam{param}); err != nil {
+		return fmt.Errorf("create table [%s] error %v", table, err)
+	}
+	m.created[table] = struct{}{}
+	m.Tables.Add(1)
+	return nil
+}
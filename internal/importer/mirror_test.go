@@ -0,0 +1,139 @@
+package importer
+
+import (
+	"context"
+	"reflect"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"testing"
+	"time"
+)
+
+// fakeMirrorTables 源超级表的子表和 tag，记录在目标库中创建的子表
+type fakeMirrorTables struct {
+	tables  map[string]map[string]string
+	queries int
+	created []db_table.TableParam
+}
+
+func (f *fakeMirrorTables) ExistingTables(_ context.Context, _ string, _ string) (map[string]map[string]string, error) {
+	f.queries++
+	return f.tables, nil
+}
+
+func (f *fakeMirrorTables) CreateTables(_ context.Context, params []db_table.TableParam) error {
+	f.created = append(f.created, params...)
+	return nil
+}
+
+func TestMirror_Poll(t *testing.T) {
+	conf := config.Config{
+		DB: config.Database{Name: "stock", Precision: "ms"},
+		STable: config.STable{
+			Name: "quotes",
+			Columns: []config.Column{
+				{Field: "ts", Type: "timestamp"},
+				{Field: "price", Type: "double"},
+			},
+			Tags: []config.Column{
+				{Field: "code", Type: "varchar(10)"},
+				{Field: "market", Type: "varchar(10)"},
+			},
+		},
+	}
+	ts := time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC)
+	table := func(name string) db_table.TableParam {
+		return db_table.TableParam{DBName: "stock_copy", STableName: "quotes", TableName: name, Precision: "ms", TagValues: []db_table.TagValue{
+			{TagName: "code", TagValue: name[2:], TagValueType: "varchar(10)"},
+			{TagName: "market", TagValueType: "varchar(10)"},
+		}}
+	}
+
+	cases := []struct {
+		name     string
+		targetDB string
+		messages []*Message
+		rows     int64
+		queries  int
+		created  []db_table.TableParam
+		commit   []uint64
+		err      bool
+	}{
+		{
+			name:     "same db",
+			targetDB: "stock",
+			err:      true,
+		},
+		{
+			name:     "mirror",
+			targetDB: "stock_copy",
+			messages: []*Message{
+				{ID: 1, Tables: []TableRows{{Table: "t_a", Rows: [][]any{{ts, 1.5}, {ts.Add(time.Second), 2.5}}}}},
+				{ID: 2, Tables: []TableRows{{Table: "t_a", Rows: [][]any{{ts.Add(2 * time.Second), 3.5}}}, {Table: "t_b", Rows: [][]any{{ts, nil}}}}},
+			},
+			rows:    4,
+			queries: 1,
+			created: []db_table.TableParam{table("t_a"), table("t_b")},
+			commit:  []uint64{1, 2},
+		},
+		{
+			name:     "unknown table",
+			targetDB: "stock_copy",
+			messages: []*Message{{ID: 1, Tables: []TableRows{{Table: "t_c", Rows: [][]any{{ts, 1.5}}}}}},
+			queries:  1,
+			err:      true,
+		},
+		{
+			name:     "values not match columns",
+			targetDB: "stock_copy",
+			messages: []*Message{{ID: 1, Tables: []TableRows{{Table: "t_a", Rows: [][]any{{ts}}}}}},
+			queries:  1,
+			created:  []db_table.TableParam{table("t_a")},
+			err:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			tables := &fakeMirrorTables{tables: map[string]map[string]string{"t_a": {"code": "a"}, "t_b": {"code": "b"}}}
+			consumer := &fakeConsumer{messages: c.messages}
+			stats := NewStats(nil)
+			m, err := NewMirror(conf, c.targetDB, consumer, tables, tables, stats, ts)
+			if err == nil {
+				for range c.messages {
+					if _, err = m.Poll(context.Background(), time.Second); err != nil {
+						break
+					}
+				}
+			}
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if m == nil {
+				return
+			}
+			if m.Rows.Load() != c.rows {
+				t.Fatalf("rows error. expect-[%d] but got-[%d]", c.rows, m.Rows.Load())
+			}
+			if tables.queries != c.queries {
+				t.Fatalf("queries error. expect-[%d] but got-[%d]", c.queries, tables.queries)
+			}
+			if !reflect.DeepEqual(tables.created, c.created) {
+				t.Fatalf("created error. expect-%v but got-%v", c.created, tables.created)
+			}
+			if !reflect.DeepEqual(consumer.committed, c.commit) {
+				t.Fatalf("commit error. expect-%v but got-%v", c.commit, consumer.committed)
+			}
+			var rows int64
+			for _, s := range stats.Tables() {
+				if s.DB != c.targetDB {
+					t.Fatalf("db of table [%s] error. expect-[%s] but got-[%s]", s.Table, c.targetDB, s.DB)
+				}
+				rows += s.Rows
+			}
+			if rows != c.rows {
+				t.Fatalf("written rows error. expect-[%d] but got-[%d]", c.rows, rows)
+			}
+		})
+	}
+}
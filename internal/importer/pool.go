@@ -0,0 +1,242 @@
+package importer
+
+import (
+	"container/list"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"taos_importer/internal/config"
+	"time"
+)
+
+const (
+	// maxCachedStmts 每个连接缓存的 STMT 数，超出时关闭最久未使用的
+	maxCachedStmts = 16
+	// healthCheckIdle 空闲超过这个时间的连接取出时先检查
+	healthCheckIdle = 30 * time.Second
+)
+
+// Pool 所有文件共用的写入连接，最多 concurrent * deal_one_time 个。
+// 连接出错或空闲过久后再取出时先 ping，失败则关闭并重新打开
+type Pool struct {
+	open         func() (connector, error)
+	protocol     string
+	maxSqlLength int // rest 写入时一条 SQL 的最大长度
+	size         int
+	slots        chan struct{} // 使用中的连接
+	mu           sync.Mutex
+	idle         []*pooledConn
+	closed       bool
+
+	opened       atomic.Int64
+	discarded    atomic.Int64
+	gets         atomic.Int64
+	waits        atomic.Int64
+	waitTime     atomic.Int64 // ns
+	stmtPrepared atomic.Int64
+	stmtReused   atomic.Int64
+}
+
+// PoolStats 连接池的统计
+type PoolStats struct {
+	Size         int
+	Opened       int64 // 打开的连接数
+	Discarded    int64 // 检查失败后关闭的连接数
+	Gets         int64 // 取连接的次数
+	Waits        int64 // 连接都在使用中需要等待的次数
+	WaitTime     time.Duration
+	StmtPrepared int64
+	StmtReused   int64 // 复用已 prepare 的 STMT 的次数
+}
+
+func (s PoolStats) String() string {
+	return fmt.Sprintf("## connection pool size-[%d] opened-[%d] discarded-[%d] gets-[%d] waits-[%d] wait-[%d] ms stmt prepared-[%d] reused-[%d]",
+		s.Size, s.Opened, s.Discarded, s.Gets, s.Waits, s.WaitTime.Milliseconds(), s.StmtPrepared, s.StmtReused)
+}
+
+// pooledConn 连接池中的一个连接，按 SQL 缓存 prepare 过的 STMT
+type pooledConn struct {
+	conn     connector
+	stmts    map[string]*list.Element // sql -> lru 中的 *cachedStmt
+	lru      *list.List
+	lastUsed time.Time
+	suspect  bool // 上次使用出错，取出时需要检查
+}
+
+type cachedStmt struct {
+	sql  string
+	stmt insertStmt
+}
+
+// NewPool 创建连接池并打开第一个连接，连接失败时返回 error
+func NewPool(conf config.Config) (*Pool, error) {
+	size := conf.Concurrent * conf.DealOneTime
+	p := newPool(func() (connector, error) { return openConnector(conf) }, size)
+	p.protocol = conf.TDEngine.GetProtocol()
+	p.maxSqlLength = conf.TDEngine.GetMaxSqlLength()
+
+	pc, err := p.get()
+	if err != nil {
+		return nil, err
+	}
+	p.put(pc, nil)
+	return p, nil
+}
+
+func newPool(open func() (connector, error), size int) *Pool {
+	if size <= 0 {
+		size = 1
+	}
+	return &Pool{open: open, size: size, slots: make(chan struct{}, size)}
+}
+
+// writer rest 连接使用 SQL 写入，其他连接使用 STMT 写入
+func (p *Pool) writer(db string, table string, columns []config.Column, precision string) (rowWriter, error) {
+	if p.protocol == config.ProtocolRest {
+		return newSqlWriter(p, db, table, columns, precision, p.maxSqlLength), nil
+	}
+	return newStmtWriter(p, db, table, columns, precision)
+}
+
+func (p *Pool) ordered() bool {
+	return false
+}
+
+// get 取出一个连接，没有空闲连接且未达到上限时打开新连接，使用后必须 put
+func (p *Pool) get() (*pooledConn, error) {
+	p.gets.Add(1)
+	select {
+	case p.slots <- struct{}{}:
+	default:
+		p.waits.Add(1)
+		start := time.Now()
+		p.slots <- struct{}{}
+		p.waitTime.Add(int64(time.Since(start)))
+	}
+
+	for {
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			<-p.slots
+			return nil, fmt.Errorf("connection pool is closed")
+		}
+		n := len(p.idle)
+		if n == 0 {
+			p.mu.Unlock()
+			break
+		}
+		pc := p.idle[n-1]
+		p.idle = p.idle[:n-1]
+		p.mu.Unlock()
+
+		if pc.suspect || time.Since(pc.lastUsed) > healthCheckIdle {
+			if err := pc.conn.ping(); err != nil {
+				log.Printf("## connection is broken, discard it. %v", err)
+				p.discarded.Add(1)
+				_ = pc.close()
+				continue
+			}
+			pc.suspect = false
+		}
+		return pc, nil
+	}
+
+	conn, err := p.open()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	p.opened.Add(1)
+	return &pooledConn{conn: conn, stmts: make(map[string]*list.Element), lru: list.New()}, nil
+}
+
+// put 归还连接，err 不为 nil 时下次取出前先检查连接
+func (p *Pool) put(pc *pooledConn, err error) {
+	pc.lastUsed = time.Now()
+	if err != nil {
+		pc.suspect = true
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		_ = pc.close()
+	} else {
+		p.idle = append(p.idle, pc)
+		p.mu.Unlock()
+	}
+	<-p.slots
+}
+
+// prepare 返回连接上按 sql prepare 过的 STMT，没有时创建并缓存
+func (p *Pool) prepare(pc *pooledConn, sql string) (insertStmt, error) {
+	if e, ok := pc.stmts[sql]; ok {
+		pc.lru.MoveToFront(e)
+		p.stmtReused.Add(1)
+		return e.Value.(*cachedStmt).stmt, nil
+	}
+
+	stmt, err := pc.conn.insertStmt()
+	if err != nil {
+		return nil, fmt.Errorf("init stmt error %v", err)
+	}
+	if err = stmt.Prepare(sql); err != nil {
+		_ = stmt.Close()
+		return nil, fmt.Errorf("prepare sql %s error %v", sql, err)
+	}
+	p.stmtPrepared.Add(1)
+
+	pc.stmts[sql] = pc.lru.PushFront(&cachedStmt{sql: sql, stmt: stmt})
+	if pc.lru.Len() > maxCachedStmts {
+		pc.discard(pc.lru.Back().Value.(*cachedStmt).sql)
+	}
+	return stmt, nil
+}
+
+// Stats 返回连接池的统计
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Size:         p.size,
+		Opened:       p.opened.Load(),
+		Discarded:    p.discarded.Load(),
+		Gets:         p.gets.Load(),
+		Waits:        p.waits.Load(),
+		WaitTime:     time.Duration(p.waitTime.Load()),
+		StmtPrepared: p.stmtPrepared.Load(),
+		StmtReused:   p.stmtReused.Load(),
+	}
+}
+
+// Close 关闭空闲连接，使用中的连接归还时关闭
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	idle := p.idle
+	p.idle = nil
+	p.closed = true
+	p.mu.Unlock()
+
+	for _, pc := range idle {
+		_ = pc.close()
+	}
+	return nil
+}
+
+// discard 关闭并删除 sql 对应的 STMT，出错后 STMT 的状态不确定，不再复用
+func (c *pooledConn) discard(sql string) {
+	e, ok := c.stmts[sql]
+	if !ok {
+		return
+	}
+	c.lru.Remove(e)
+	delete(c.stmts, sql)
+	_ = e.Value.(*cachedStmt).stmt.Close()
+}
+
+func (c *pooledConn) close() error {
+	for sql := range c.stmts {
+		c.discard(sql)
+	}
+	return c.conn.Close()
+}
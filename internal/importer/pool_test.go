@@ -0,0 +1,210 @@
+package importer
+
+import (
+	"fmt"
+	"sync"
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+
+	"github.com/taosdata/driver-go/v3/common/param"
+)
+
+// fakeConnector 记录 prepare 和关闭的 STMT，broken 时 ping 和 prepare 失败
+type fakeConnector struct {
+	mu       sync.Mutex
+	broken   bool
+	closed   bool
+	prepared []string
+	stmts    []*fakeStmt
+}
+
+type fakeStmt struct {
+	conn   *fakeConnector
+	closed bool
+}
+
+func (c *fakeConnector) insertStmt() (insertStmt, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s := &fakeStmt{conn: c}
+	c.stmts = append(c.stmts, s)
+	return s, nil
+}
+
+func (c *fakeConnector) ping() error {
+	if c.broken {
+		return fmt.Errorf("broken")
+	}
+	return nil
+}
+
+func (c *fakeConnector) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (s *fakeStmt) Prepare(sql string) error {
+	if s.conn.broken {
+		return fmt.Errorf("broken")
+	}
+	s.conn.mu.Lock()
+	defer s.conn.mu.Unlock()
+	s.conn.prepared = append(s.conn.prepared, sql)
+	return nil
+}
+
+func (s *fakeStmt) SetTableName(_ string) error                           { return nil }
+func (s *fakeStmt) BindParam(_ []*param.Param, _ *param.ColumnType) error { return nil }
+func (s *fakeStmt) AddBatch() error                                       { return nil }
+func (s *fakeStmt) Execute() error                                        { return nil }
+func (s *fakeStmt) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestPool_Reuse(t *testing.T) {
+	var conns []*fakeConnector
+	pool := newPool(func() (connector, error) {
+		c := &fakeConnector{}
+		conns = append(conns, c)
+		return c, nil
+	}, 2)
+
+	// 同一个 SQL 在连接上只 prepare 一次
+	for i := 0; i < 3; i++ {
+		pc, err := pool.get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = pool.prepare(pc, "insert into t_1 values (?)"); err != nil {
+			t.Fatal(err)
+		}
+		pool.put(pc, nil)
+	}
+	stats := pool.Stats()
+	if len(conns) != 1 || stats.Opened != 1 || stats.StmtPrepared != 1 || stats.StmtReused != 2 || stats.Gets != 3 {
+		t.Errorf("conns %d stats %+v", len(conns), stats)
+	}
+
+	// 最多 size 个连接，用完后等待
+	a, _ := pool.get()
+	b, _ := pool.get()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c, err := pool.get()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		pool.put(c, nil)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	pool.put(a, nil)
+	<-done
+	pool.put(b, nil)
+	stats = pool.Stats()
+	if len(conns) != 2 || stats.Waits != 1 {
+		t.Errorf("conns %d stats %+v", len(conns), stats)
+	}
+
+	_ = pool.Close()
+	for _, c := range conns {
+		if !c.closed {
+			t.Error("connection is not closed")
+		}
+		for _, s := range c.stmts {
+			if !s.closed {
+				t.Error("stmt is not closed")
+			}
+		}
+	}
+	if _, err := pool.get(); err == nil {
+		t.Error("expect error of closed pool")
+	}
+}
+
+func TestPool_HealthCheck(t *testing.T) {
+	cases := []struct {
+		name      string
+		err       error
+		broken    bool
+		opened    int64
+		discarded int64
+	}{
+		{name: "ok", opened: 1},
+		{name: "error but alive", err: fmt.Errorf("table not exist"), opened: 1},
+		{name: "broken", err: fmt.Errorf("connection reset"), broken: true, opened: 2, discarded: 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			pool := newPool(func() (connector, error) { return &fakeConnector{}, nil }, 1)
+			defer func() { _ = pool.Close() }()
+
+			pc, err := pool.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			pc.conn.(*fakeConnector).broken = c.broken
+			pool.put(pc, c.err)
+
+			if pc, err = pool.get(); err != nil {
+				t.Fatal(err)
+			}
+			pool.put(pc, nil)
+			stats := pool.Stats()
+			if stats.Opened != c.opened || stats.Discarded != c.discarded {
+				t.Errorf("stats %+v", stats)
+			}
+		})
+	}
+}
+
+func TestPool_StmtEvict(t *testing.T) {
+	conn := &fakeConnector{}
+	pool := newPool(func() (connector, error) { return conn, nil }, 1)
+	defer func() { _ = pool.Close() }()
+
+	pc, err := pool.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i <= maxCachedStmts; i++ {
+		if _, err = pool.prepare(pc, fmt.Sprintf("insert into t_%d values (?)", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 最久未使用的 t_0 被关闭
+	if len(pc.stmts) != maxCachedStmts || !conn.stmts[0].closed || conn.stmts[1].closed {
+		t.Errorf("cached %d", len(pc.stmts))
+	}
+
+	// 出错后不再复用
+	pc.discard("insert into t_1 values (?)")
+	if _, err = pool.prepare(pc, "insert into t_1 values (?)"); err != nil {
+		t.Fatal(err)
+	}
+	pool.put(pc, nil)
+	if stats := pool.Stats(); stats.StmtPrepared != maxCachedStmts+2 || stats.StmtReused != 0 {
+		t.Errorf("stats %+v", stats)
+	}
+}
+
+func TestNewPool(t *testing.T) {
+	conf := config.Config{TDEngine: config.TDEngine{Protocol: config.ProtocolWS, Host: "127.0.0.1", Port: 1}, Concurrent: 2, DealOneTime: 3}
+	if _, err := NewPool(conf); err == nil {
+		t.Error("expect error of unreachable server")
+	}
+
+	conf.TDEngine.Protocol = config.ProtocolRest
+	pool, err := NewPool(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = pool.Close() }()
+	if stats := pool.Stats(); stats.Size != 6 || stats.Opened != 1 {
+		t.Errorf("stats %+v", stats)
+	}
+}
@@ -0,0 +1,32 @@
+package importer
+
+import "sync"
+
+// sequencer 让并发处理的批次按编号依次进入临界区
+type sequencer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	next int
+}
+
+// wait 阻塞直到轮到编号为 seq 的批次
+func (s *sequencer) wait(seq int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cond == nil {
+		s.cond = sync.NewCond(&s.mu)
+	}
+	for s.next != seq {
+		s.cond.Wait()
+	}
+}
+
+// done 释放临界区给下一个批次
+func (s *sequencer) done() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	if s.cond != nil {
+		s.cond.Broadcast()
+	}
+}
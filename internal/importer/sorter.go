@@ -0,0 +1,103 @@
+package importer
+
+import (
+	"container/heap"
+	"fmt"
+	"taos_importer/internal/config"
+	"time"
+)
+
+const (
+	SortFile   = "file"   // 缓存整个文件，导入结束时按时间戳输出
+	SortWindow = "window" // 每个子表最多缓存 size 行，超出时输出时间戳最小的行
+
+	defaultSortSize = 10000
+)
+
+// sorter 按时间戳对同一子表的行排序，时间戳相同时保持文件中的顺序。
+// window 模式下只能纠正相距不超过 size 行的乱序，更早输出的行不再参与排序。
+type sorter struct {
+	scope string
+	size  int
+	index int
+	count int // 已加入的行数，用于时间戳相同的行保持原来的顺序
+	rows  rowHeap
+}
+
+func newSorter(conf config.Sort, index int) (*sorter, error) {
+	switch conf.Scope {
+	case "":
+		return nil, nil
+	case SortFile, SortWindow:
+	default:
+		return nil, fmt.Errorf("unknown sort scope [%s]", conf.Scope)
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("sort needs a timestamp column")
+	}
+
+	s := &sorter{scope: conf.Scope, size: conf.Size, index: index}
+	if s.size <= 0 {
+		s.size = defaultSortSize
+	}
+	s.rows.index = index
+	return s, nil
+}
+
+// add 加入一批行，返回可以输出的行。没有时间戳的行直接输出
+func (s *sorter) add(rows []row) []row {
+	var out []row
+	for _, r := range rows {
+		if _, ok := r.values[s.index].(time.Time); !ok {
+			out = append(out, r)
+			continue
+		}
+		s.count++
+		heap.Push(&s.rows, sortedRow{row: r, order: s.count})
+		if s.scope == SortWindow && s.rows.Len() > s.size {
+			out = append(out, heap.Pop(&s.rows).(sortedRow).row)
+		}
+	}
+	return out
+}
+
+// flush 按时间戳输出所有缓存的行
+func (s *sorter) flush() []row {
+	out := make([]row, 0, s.rows.Len())
+	for s.rows.Len() > 0 {
+		out = append(out, heap.Pop(&s.rows).(sortedRow).row)
+	}
+	return out
+}
+
+type sortedRow struct {
+	row
+	order int
+}
+
+// rowHeap 按 (时间戳, 加入顺序) 排序的最小堆
+type rowHeap struct {
+	index int
+	rows  []sortedRow
+}
+
+func (h *rowHeap) Len() int { return len(h.rows) }
+
+func (h *rowHeap) Less(i, j int) bool {
+	x, y := h.rows[i].values[h.index].(time.Time), h.rows[j].values[h.index].(time.Time)
+	if x.Equal(y) {
+		return h.rows[i].order < h.rows[j].order
+	}
+	return x.Before(y)
+}
+
+func (h *rowHeap) Swap(i, j int) { h.rows[i], h.rows[j] = h.rows[j], h.rows[i] }
+
+func (h *rowHeap) Push(x any) { h.rows = append(h.rows, x.(sortedRow)) }
+
+func (h *rowHeap) Pop() any {
+	n := len(h.rows)
+	r := h.rows[n-1]
+	h.rows = h.rows[:n-1]
+	return r
+}
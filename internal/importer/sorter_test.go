@@ -0,0 +1,73 @@
+package importer
+
+import (
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+)
+
+func TestSorter(t *testing.T) {
+	base := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	ms := func(n int) time.Time { return base.Add(time.Duration(n) * time.Millisecond) }
+
+	cases := []struct {
+		name    string
+		conf    config.Sort
+		batches [][]time.Time
+		added   []int // 每批 add 后输出的行数
+		expect  []int // 输出行的行号
+	}{
+		{
+			name:    "file",
+			conf:    config.Sort{Scope: SortFile},
+			batches: [][]time.Time{{ms(3), ms(1)}, {ms(2), ms(1), ms(0)}},
+			added:   []int{0, 0},
+			expect:  []int{5, 2, 4, 3, 1},
+		},
+		{
+			name:    "window",
+			conf:    config.Sort{Scope: SortWindow, Size: 2},
+			batches: [][]time.Time{{ms(3), ms(1)}, {ms(2), ms(5), ms(0)}},
+			added:   []int{0, 3},
+			expect:  []int{2, 3, 5, 1, 4},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := newSorter(c.conf, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var res []row
+			num := 0
+			for i, b := range c.batches {
+				rows := make([]row, 0, len(b))
+				for _, ts := range b {
+					num++
+					rows = append(rows, row{line: num, values: []any{ts}})
+				}
+				out := s.add(rows)
+				if len(out) != c.added[i] {
+					t.Fatalf("batch-[%d] expect %d rows but got %d", i, c.added[i], len(out))
+				}
+				res = append(res, out...)
+			}
+			res = append(res, s.flush()...)
+
+			if len(res) != len(c.expect) {
+				t.Fatalf("expect %d rows but got %d", len(c.expect), len(res))
+			}
+			for i, r := range res {
+				if r.line != c.expect[i] {
+					t.Fatalf("row-[%d] expect line-[%d] but got line-[%d]", i, c.expect[i], r.line)
+				}
+			}
+		})
+	}
+
+	if _, err := newSorter(config.Sort{Scope: "table"}, 0); err == nil {
+		t.Fatal("unknown scope should fail")
+	}
+}
@@ -0,0 +1,119 @@
+package importer
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"taos_importer/internal/config"
+)
+
+// SqlFile 把数据写为 insert 语句，用于不能连接 TDengine 的环境，之后用 taos -f 执行。
+// 文件名为 data_0001.sql、data_0002.sql ...，超过 maxSize 时换下一个文件，每条语句一行。
+// 按批次顺序写入，同样的输入得到同样的文件
+type SqlFile struct {
+	mu           sync.Mutex
+	dir          string
+	maxSize      int64
+	maxSqlLength int
+	file         *os.File
+	buffer       *bufio.Writer
+	size         int64
+	files        []string
+	statements   int64
+}
+
+func NewSqlFile(dir string, maxSize int64, maxSqlLength int) *SqlFile {
+	return &SqlFile{dir: dir, maxSize: maxSize, maxSqlLength: maxSqlLength}
+}
+
+func (s *SqlFile) writer(db string, table string, columns []config.Column, precision string) (rowWriter, error) {
+	return &sqlFileWriter{file: s, render: newSqlWriter(nil, db, table, columns, precision, s.maxSqlLength)}, nil
+}
+
+func (s *SqlFile) ordered() bool {
+	return true
+}
+
+// write 每条语句一行，写入前超过 maxSize 时换下一个文件
+func (s *SqlFile) write(sqls []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, sql := range sqls {
+		line := sql + ";\n"
+		if s.file == nil || (s.size > 0 && s.size+int64(len(line)) > s.maxSize) {
+			if err := s.rotate(); err != nil {
+				return err
+			}
+		}
+		if _, err := s.buffer.WriteString(line); err != nil {
+			return err
+		}
+		s.size += int64(len(line))
+		s.statements++
+	}
+	return nil
+}
+
+func (s *SqlFile) rotate() error {
+	if err := s.closeFile(); err != nil {
+		return err
+	}
+	name := filepath.Join(s.dir, fmt.Sprintf("data_%04d.sql", len(s.files)+1))
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	s.file, s.buffer, s.size = f, bufio.NewWriter(f), 0
+	s.files = append(s.files, name)
+	return nil
+}
+
+func (s *SqlFile) closeFile() error {
+	if s.file == nil {
+		return nil
+	}
+	f := s.file
+	s.file = nil
+	if err := s.buffer.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Files 返回已写入的文件，按执行顺序排列
+func (s *SqlFile) Files() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.files...)
+}
+
+// Statements 返回已写入的语句数
+func (s *SqlFile) Statements() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.statements
+}
+
+func (s *SqlFile) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeFile()
+}
+
+// sqlFileWriter 和 rest 写入使用相同的 insert 语句
+type sqlFileWriter struct {
+	file   *SqlFile
+	render *sqlWriter
+}
+
+func (w *sqlFileWriter) write(rows []row) error {
+	sqls, err := w.render.sql(rows)
+	if err != nil {
+		return fmt.Errorf("parse params error %v", err)
+	}
+	return w.file.write(sqls)
+}
@@ -0,0 +1,99 @@
+package importer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"taos_importer/internal/config"
+	"testing"
+)
+
+func TestSqlFile(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "double"},
+	}
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{"1669196785000", "1.5"}},
+		{line: 2, table: "t_2", values: []any{"1669196786000", "2"}},
+		{line: 3, table: "t_1", values: []any{"1669196787000", "3.25"}},
+	}
+	line := "insert into `stock`.`t_1` values ('2022-11-23T09:46:25Z', 1.5);\n"
+
+	cases := []struct {
+		name    string
+		maxSize int64
+		batches [][]row
+		files   []string
+		lines   int
+	}{
+		{
+			name:    "one file",
+			maxSize: 1 << 20,
+			batches: [][]row{rows, rows},
+			files:   []string{"data_0001.sql"},
+			lines:   2,
+		},
+		{
+			name:    "rotate",
+			maxSize: int64(len(line)),
+			batches: [][]row{rows[:1], rows[:1], rows[:1]},
+			files:   []string{"data_0001.sql", "data_0002.sql", "data_0003.sql"},
+			lines:   3,
+		},
+		{
+			// 一条语句超过 maxSize 时单独写一个文件
+			name:    "statement longer than max size",
+			maxSize: 10,
+			batches: [][]row{rows[:1], rows[:1]},
+			files:   []string{"data_0001.sql", "data_0002.sql"},
+			lines:   2,
+		},
+		{
+			name: "no data",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s := NewSqlFile(dir, c.maxSize, 1048576)
+			w, err := s.writer("stock", "", columns, "ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, batch := range c.batches {
+				if err = w.write(batch); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err = s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var files []string
+			content := ""
+			for _, f := range s.Files() {
+				files = append(files, filepath.Base(f))
+				b, err := os.ReadFile(f)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if c.maxSize > int64(len(line)) && int64(len(b)) > c.maxSize {
+					t.Errorf("size of %s is %d, longer than %d", f, len(b), c.maxSize)
+				}
+				content += string(b)
+			}
+			if !reflect.DeepEqual(files, c.files) {
+				t.Errorf("files %v, expect %v", files, c.files)
+			}
+			if n := strings.Count(content, ";\n"); n != c.lines || s.Statements() != int64(c.lines) {
+				t.Errorf("statements %d %d, expect %d", n, s.Statements(), c.lines)
+			}
+			if len(c.batches) > 0 && !strings.HasPrefix(content, "insert into `stock`.`t_1` values ('2022-11-23T09:46:25Z', 1.5)") {
+				t.Errorf("content %s", content)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+)
+
+const insertPrefix = "insert into"
+
+// sqlWriter 把一批行渲染为 insert into `db`.`t1` values (...)(...) `db`.`t2` values (...) 通过 rest 写入，
+// 值的格式和建表时的 tag 相同。SQL 超过 maxSqlLength 时拆分为多条。table 为空时按 row.table 写入多个子表
+type sqlWriter struct {
+	pool         *Pool
+	db           string
+	table        string
+	columns      []config.Column
+	timeUnit     string // 整数时间戳的精度
+	maxSqlLength int
+}
+
+func newSqlWriter(pool *Pool, db string, table string, columns []config.Column, precision string, maxSqlLength int) *sqlWriter {
+	return &sqlWriter{
+		pool:         pool,
+		db:           db,
+		table:        table,
+		columns:      columns,
+		timeUnit:     precision,
+		maxSqlLength: maxSqlLength,
+	}
+}
+
+func (w *sqlWriter) write(rows []row) (err error) {
+	sqls, err := w.sql(rows)
+	if err != nil {
+		return fmt.Errorf("parse params error %v", err)
+	}
+
+	pc, err := w.pool.get()
+	if err != nil {
+		return fmt.Errorf("get connection error %v", err)
+	}
+	defer func() { w.pool.put(pc, err) }()
+	conn := pc.conn.(*restConnector)
+
+	for _, sql := range sqls {
+		if err = conn.exec(sql); err != nil {
+			return fmt.Errorf("insert data error %v", err)
+		}
+	}
+	return nil
+}
+
+// sql 按子表分组渲染 insert 语句，每条不超过 maxSqlLength
+func (w *sqlWriter) sql(rows []row) ([]string, error) {
+	sqls := make([]string, 0)
+	var buffer strings.Builder
+
+	tables, groups := groupByTable(rows)
+	for _, table := range tables {
+		name := w.table
+		if len(name) == 0 {
+			if len(table) == 0 {
+				return nil, fmt.Errorf("table name of line-[%d] is null", groups[table][0].line)
+			}
+			name = table
+		}
+		identifier, err := db_table.TableIdentifier(w.db, name)
+		if err != nil {
+			return nil, err
+		}
+		clause := " " + identifier + " values "
+
+		opened := false // 当前 SQL 中已经写入了这个子表的 values
+		for _, r := range groups[table] {
+			values, err := w.values(r)
+			if err != nil {
+				return nil, err
+			}
+			length := len(values)
+			if !opened {
+				length += len(clause)
+			}
+			if buffer.Len() > 0 && buffer.Len()+length > w.maxSqlLength {
+				sqls = append(sqls, buffer.String())
+				buffer.Reset()
+				opened = false
+				length = len(clause) + len(values)
+			}
+			if buffer.Len() == 0 {
+				if len(insertPrefix)+length > w.maxSqlLength {
+					return nil, fmt.Errorf("sql of line-[%d] is longer than max sql length %d", r.line, w.maxSqlLength)
+				}
+				buffer.WriteString(insertPrefix)
+			}
+			if !opened {
+				buffer.WriteString(clause)
+				opened = true
+			}
+			buffer.WriteString(values)
+		}
+	}
+	if buffer.Len() > 0 {
+		sqls = append(sqls, buffer.String())
+	}
+	return sqls, nil
+}
+
+// values 渲染一行为 (v1, v2, ...)，转换失败时返回的 error 包含行号和列名
+func (w *sqlWriter) values(r row) (string, error) {
+	literals := make([]string, 0, len(w.columns))
+	for i, column := range w.columns {
+		value, err := common.Convert(r.values[i], column.Type, w.timeUnit)
+		if err != nil {
+			return "", fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+		}
+		literal, err := db_table.Literal(value, column.Type, w.timeUnit)
+		if err != nil {
+			return "", fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+		}
+		literals = append(literals, literal)
+	}
+	return "(" + strings.Join(literals, ", ") + ")", nil
+}
@@ -0,0 +1,253 @@
+package importer
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+)
+
+func TestSqlWriter_Sql(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "float"},
+		{Field: "name", Type: "nchar(16)"},
+	}
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{"1669196785000", "1.5", "a'b"}},
+		{line: 2, table: "t_2", values: []any{"1669196786000", "2", nil}},
+		{line: 3, table: "t_1", values: []any{"1669196787000", "3.25", "c"}},
+	}
+	row1 := `('2022-11-23T09:46:25Z', 1.5, 'a\'b')`
+	row2 := `('2022-11-23T09:46:26Z', 2, NULL)`
+	row3 := `('2022-11-23T09:46:27Z', 3.25, 'c')`
+	t1 := " `stock`.`t_1` values "
+	t2 := " `stock`.`t_2` values "
+
+	cases := []struct {
+		name   string
+		table  string
+		max    int
+		rows   []row
+		expect []string
+		err    bool
+	}{
+		{
+			name:   "one sql",
+			max:    1048576,
+			rows:   rows,
+			expect: []string{"insert into" + t1 + row1 + row3 + t2 + row2},
+		},
+		{
+			name: "split by table",
+			max:  len("insert into" + t1 + row1 + row3),
+			rows: rows,
+			expect: []string{
+				"insert into" + t1 + row1 + row3,
+				"insert into" + t2 + row2,
+			},
+		},
+		{
+			name: "split in table",
+			max:  len("insert into"+t1+row1+row3) - 1,
+			rows: rows,
+			expect: []string{
+				"insert into" + t1 + row1,
+				"insert into" + t1 + row3,
+				"insert into" + t2 + row2,
+			},
+		},
+		{
+			name:   "writer table",
+			table:  "t_9",
+			max:    1048576,
+			rows:   []row{{line: 1, values: rows[0].values}},
+			expect: []string{"insert into `stock`.`t_9` values " + row1},
+		},
+		{
+			name: "too long",
+			max:  len("insert into" + t1 + row1 + row3),
+			rows: []row{{line: 1, table: "t_1", values: []any{"1669196785000", "1.5", strings.Repeat("x", 100)}}},
+			err:  true,
+		},
+		{
+			name: "convert error",
+			max:  1048576,
+			rows: []row{{line: 7, table: "t_1", values: []any{"1669196785000", "abc", "a"}}},
+			err:  true,
+		},
+		{
+			name: "null table",
+			max:  1048576,
+			rows: []row{{line: 1, values: rows[0].values}},
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := newSqlWriter(nil, "stock", c.table, columns, "ms", c.max)
+			sqls, err := w.sql(c.rows)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if c.err {
+				return
+			}
+			if !reflect.DeepEqual(sqls, c.expect) {
+				t.Errorf("\n%v\nexpect\n%v", sqls, c.expect)
+			}
+			for _, sql := range sqls {
+				if len(sql) > c.max {
+					t.Errorf("length of sql %d is longer than %d", len(sql), c.max)
+				}
+			}
+		})
+	}
+}
+
+// fakeRest 模拟 taosAdapter 的 /rest/sql 接口，记录收到的 SQL，code 不为 0 时返回错误
+type fakeRest struct {
+	mu    sync.Mutex
+	sqls  []string
+	auth  []string
+	code  int
+	token string
+}
+
+func (f *fakeRest) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/rest/sql" || r.Method != http.MethodPost {
+		http.NotFound(w, r)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	f.mu.Lock()
+	f.sqls = append(f.sqls, string(body))
+	f.auth = append(f.auth, r.Header.Get("Authorization")+r.URL.Query().Get("token"))
+	f.mu.Unlock()
+
+	if len(f.token) > 0 && r.URL.Query().Get("token") != f.token {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = fmt.Fprint(w, `{"code":65535,"desc":"unauthorized"}`)
+		return
+	}
+	if f.code != 0 {
+		_, _ = fmt.Fprintf(w, `{"code":%d,"desc":"Table does not exist"}`, f.code)
+		return
+	}
+	_, _ = fmt.Fprint(w, `{"code":0,"column_meta":[["affected_rows","INT",4]],"data":[[1]],"rows":1}`)
+}
+
+func TestSqlWriter_Write(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "double"},
+	}
+	ts := time.Date(2022, 11, 23, 9, 46, 25, 0, time.UTC)
+	rows := []row{
+		{line: 1, table: "t_1", values: []any{ts, "1.5"}},
+		{line: 2, table: "t_2", values: []any{ts, "2.5"}},
+	}
+	basic := "Basic " + base64.StdEncoding.EncodeToString([]byte("root:taosdata"))
+
+	cases := []struct {
+		name   string
+		conf   config.TDEngine
+		server *fakeRest
+		sqls   int
+		auth   string
+		err    bool
+	}{
+		{
+			name: "basic auth",
+			conf: config.TDEngine{User: "root", Password: "taosdata"},
+			sqls: 1,
+			auth: basic,
+		},
+		{
+			name:   "token",
+			conf:   config.TDEngine{User: "root", Password: "taosdata", Token: "abc"},
+			server: &fakeRest{token: "abc"},
+			sqls:   1,
+			auth:   "abc",
+		},
+		{
+			name:   "wrong token",
+			conf:   config.TDEngine{Token: "abd"},
+			server: &fakeRest{token: "abc"},
+			sqls:   1,
+			auth:   "abd",
+			err:    true,
+		},
+		{
+			name: "split",
+			conf: config.TDEngine{User: "root", Password: "taosdata", MaxSqlLength: 64},
+			sqls: 2,
+			auth: basic,
+		},
+		{
+			name:   "error code",
+			conf:   config.TDEngine{User: "root", Password: "taosdata"},
+			server: &fakeRest{code: 0x2603},
+			sqls:   1,
+			auth:   basic,
+			err:    true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			adapter := c.server
+			if adapter == nil {
+				adapter = &fakeRest{}
+			}
+			server := httptest.NewServer(adapter)
+			defer server.Close()
+
+			host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+			if err != nil {
+				t.Fatal(err)
+			}
+			conf := config.Config{DB: config.Database{Name: "stock", Precision: "ms"}, TDEngine: c.conf}
+			conf.TDEngine.Protocol = config.ProtocolRest
+			conf.TDEngine.Host = host
+			if conf.TDEngine.Port, err = strconv.Atoi(port); err != nil {
+				t.Fatal(err)
+			}
+
+			pool, err := NewPool(conf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = pool.Close() }()
+			w, err := pool.writer(conf.DB.Name, "", columns, conf.DB.Precision)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err = w.write(rows); (err != nil) != c.err {
+				t.Fatalf("write error %v, expect error %v", err, c.err)
+			}
+
+			if len(adapter.sqls) != c.sqls {
+				t.Errorf("sqls %v, expect %d", adapter.sqls, c.sqls)
+			}
+			for i, sql := range adapter.sqls {
+				if !strings.HasPrefix(sql, "insert into `stock`.") {
+					t.Errorf("sql %s", sql)
+				}
+				if adapter.auth[i] != c.auth {
+					t.Errorf("auth %s, expect %s", adapter.auth[i], c.auth)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package importer
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"time"
+)
+
+// Stats 不写入数据，按子表统计写入后应有的结果，用于和 TDengine 中的数据核对。
+// 每个子表只保存行数、时间范围和各列的和，不保存行。时间戳相同的行 TDengine 只保留最后写入的，
+// 这里只合并相邻的相同时间戳（保留最后的行），不相邻的重复时间戳需要由 dedup 处理，否则会重复计算
+type Stats struct {
+	mu     sync.Mutex
+	sums   []string // 需要求和的列，写入的列中没有的忽略
+	tables map[string]*tableStats
+}
+
+// TableStats 一个子表的统计，Rows 为不同时间戳的行数
+type TableStats struct {
+	DB       string
+	Table    string
+	TsColumn string
+	Rows     int64
+	First    time.Time
+	Last     time.Time
+	Sums     map[string]float64
+}
+
+type tableStats struct {
+	db          string
+	table       string
+	tsColumn    string
+	sums        []string
+	rows        int64
+	first, last int64 // unix ns
+	totals      []float64
+	previous    int64      // 上一行的时间戳，unix ns
+	values      []*float64 // 上一行 sum 列的值，null 为 nil
+}
+
+// add 加入一行，时间戳与上一行相同时替换上一行
+func (t *tableStats) add(ts int64, values []*float64) {
+	if t.rows > 0 && ts == t.previous {
+		for i, v := range t.values {
+			if v != nil {
+				t.totals[i] -= *v
+			}
+		}
+	} else {
+		if t.rows == 0 || ts < t.first {
+			t.first = ts
+		}
+		if t.rows == 0 || ts > t.last {
+			t.last = ts
+		}
+		t.rows++
+	}
+	for i, v := range values {
+		if v != nil {
+			t.totals[i] += *v
+		}
+	}
+	t.previous, t.values = ts, values
+}
+
+func NewStats(sums []string) *Stats {
+	return &Stats{sums: sums, tables: make(map[string]*tableStats)}
+}
+
+func (s *Stats) writer(db string, table string, columns []config.Column, precision string) (rowWriter, error) {
+	if len(columns) == 0 || columns[0].Type != common.TypeTimeStamp {
+		return nil, fmt.Errorf("first column of table is not timestamp")
+	}
+	w := &statsWriter{stats: s, db: db, table: table, columns: columns, timeUnit: precision}
+	for _, sum := range s.sums {
+		for i, column := range columns {
+			if column.Field == sum {
+				w.sumIndex = append(w.sumIndex, i)
+			}
+		}
+	}
+	return w, nil
+}
+
+// ordered 时间戳相同时按文件中的顺序保留最后的行
+func (s *Stats) ordered() bool {
+	return true
+}
+
+func (s *Stats) Close() error {
+	return nil
+}
+
+// Tables 返回所有子表的统计，按库名、子表名排序
+func (s *Stats) Tables() []TableStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tables := make([]TableStats, 0, len(s.tables))
+	for _, t := range s.tables {
+		result := TableStats{DB: t.db, Table: t.table, TsColumn: t.tsColumn, Rows: t.rows, Sums: make(map[string]float64, len(t.sums))}
+		for i, sum := range t.sums {
+			result.Sums[sum] = t.totals[i]
+		}
+		if t.rows > 0 {
+			result.First, result.Last = time.Unix(0, t.first), time.Unix(0, t.last)
+		}
+		tables = append(tables, result)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		if tables[i].DB != tables[j].DB {
+			return tables[i].DB < tables[j].DB
+		}
+		return tables[i].Table < tables[j].Table
+	})
+	return tables
+}
+
+func (s *Stats) table(db string, table string, tsColumn string, sums []string) *tableStats {
+	key := db + "." + table
+	t, ok := s.tables[key]
+	if !ok {
+		t = &tableStats{db: db, table: table, tsColumn: tsColumn, sums: sums, totals: make([]float64, len(sums))}
+		s.tables[key] = t
+	}
+	return t
+}
+
+type statsWriter struct {
+	stats    *Stats
+	db       string
+	table    string
+	columns  []config.Column
+	timeUnit string
+	sumIndex []int
+}
+
+func (w *statsWriter) write(rows []row) error {
+	sums := make([]string, 0, len(w.sumIndex))
+	for _, i := range w.sumIndex {
+		sums = append(sums, w.columns[i].Field)
+	}
+
+	w.stats.mu.Lock()
+	defer w.stats.mu.Unlock()
+	for _, r := range rows {
+		name := w.table
+		if len(name) == 0 {
+			name = r.table
+		}
+		if len(name) == 0 {
+			return fmt.Errorf("table name of line-[%d] is null", r.line)
+		}
+		ts, err := common.Convert(r.values[0], common.TypeTimeStamp, w.timeUnit)
+		if err != nil {
+			return fmt.Errorf("line-[%d] column-[%s] %v", r.line, w.columns[0].Field, err)
+		}
+		if ts == nil {
+			return fmt.Errorf("line-[%d] timestamp is null", r.line)
+		}
+
+		values := make([]*float64, len(w.sumIndex))
+		for i, index := range w.sumIndex {
+			column := w.columns[index]
+			value, err := common.Convert(r.values[index], column.Type, w.timeUnit)
+			if err != nil {
+				return fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+			}
+			if value == nil {
+				continue
+			}
+			v, err := common.Float64(value)
+			if err != nil {
+				return fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+			}
+			values[i] = &v
+		}
+		w.stats.table(w.db, name, w.columns[0].Field, sums).add(ts.(time.Time).UnixNano(), values)
+	}
+	return nil
+}
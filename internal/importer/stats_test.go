@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"reflect"
+	"taos_importer/internal/config"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	columns := []config.Column{
+		{Field: "ts", Type: "timestamp"},
+		{Field: "price", Type: "float"},
+		{Field: "volume", Type: "bigint unsigned"},
+		{Field: "name", Type: "nchar(10)"},
+	}
+	ts := time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC)
+
+	cases := []struct {
+		name   string
+		table  string
+		rows   []row
+		expect []TableStats
+		err    bool
+	}{
+		{
+			name: "last row of same timestamp",
+			rows: []row{
+				{line: 1, table: "t_2", values: []any{"1669167985000", "1.5", "10", "a"}},
+				{line: 2, table: "t_1", values: []any{"1669167985000", "3", nil, "c"}},
+				{line: 3, table: "t_1", values: []any{"1669167986000", "2", "20", "b"}},
+				{line: 4, table: "t_1", values: []any{"1669167986000", "4.5", "40", "d"}},
+			},
+			expect: []TableStats{
+				{DB: "stock", Table: "t_1", TsColumn: "ts", Rows: 2, First: ts, Last: ts.Add(time.Second), Sums: map[string]float64{"price": 7.5, "volume": 40}},
+				{DB: "stock", Table: "t_2", TsColumn: "ts", Rows: 1, First: ts, Last: ts, Sums: map[string]float64{"price": 1.5, "volume": 10}},
+			},
+		},
+		{
+			// 不相邻的重复时间戳由 dedup 处理，这里不去重
+			name: "not adjacent",
+			rows: []row{
+				{line: 1, table: "t_1", values: []any{"1669167986000", "2", "20", "b"}},
+				{line: 2, table: "t_1", values: []any{"1669167985000", "3", nil, "c"}},
+				{line: 3, table: "t_1", values: []any{"1669167986000", "4.5", "40", "d"}},
+			},
+			expect: []TableStats{
+				{DB: "stock", Table: "t_1", TsColumn: "ts", Rows: 3, First: ts, Last: ts.Add(time.Second), Sums: map[string]float64{"price": 9.5, "volume": 60}},
+			},
+		},
+		{
+			name:  "writer table",
+			table: "t_9",
+			rows:  []row{{line: 1, values: []any{"1669167985000", nil, nil, "a"}}},
+			expect: []TableStats{
+				{DB: "stock", Table: "t_9", TsColumn: "ts", Rows: 1, First: ts, Last: ts, Sums: map[string]float64{"price": 0, "volume": 0}},
+			},
+		},
+		{
+			name: "null timestamp",
+			rows: []row{{line: 1, table: "t_1", values: []any{nil, "1", "1", "a"}}},
+			err:  true,
+		},
+		{
+			name: "convert error",
+			rows: []row{{line: 1, table: "t_1", values: []any{"1669167985000", "abc", "1", "a"}}},
+			err:  true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s := NewStats([]string{"price", "volume", "amount"})
+			w, err := s.writer("stock", c.table, columns, "ms")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = w.write(c.rows)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if c.err {
+				return
+			}
+			tables := s.Tables()
+			for i := range tables {
+				tables[i].First, tables[i].Last = tables[i].First.UTC(), tables[i].Last.UTC()
+			}
+			if !reflect.DeepEqual(tables, c.expect) {
+				t.Errorf("\n%+v\nexpect\n%+v", tables, c.expect)
+			}
+		})
+	}
+
+	if _, err := NewStats(nil).writer("stock", "", columns[1:], "ms"); err == nil {
+		t.Error("expect error of no timestamp column")
+	}
+}
@@ -0,0 +1,265 @@
+package importer
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"taos_importer/internal/common"
+	"taos_importer/internal/config"
+	"taos_importer/internal/db_table"
+	"time"
+
+	common2 "github.com/taosdata/driver-go/v3/common"
+	"github.com/taosdata/driver-go/v3/common/param"
+)
+
+// stmtWriter 通过 STMT 写入一个子表，table 为空时按 row.table 写入多个子表。
+// 连接和 prepare 过的 STMT 从连接池中取，同一个 SQL 的 STMT 在连接上复用
+type stmtWriter struct {
+	pool        *Pool
+	db          string
+	table       string
+	columns     []config.Column
+	precision   int
+	timeUnit    string // 整数时间戳的精度
+	columnTypes *param.ColumnType
+	insertSql   string
+}
+
+func newStmtWriter(pool *Pool, db string, table string, columns []config.Column, precision string) (w *stmtWriter, err error) {
+	w = &stmtWriter{
+		pool:      pool,
+		db:        db,
+		table:     table,
+		columns:   columns,
+		precision: dbPrecision(precision),
+		timeUnit:  precision,
+	}
+	if w.insertSql, err = w.stmtSql(); err != nil {
+		return w, err
+	}
+	w.columnTypes, err = w.columnType()
+	return w, err
+}
+
+func (w *stmtWriter) write(rows []row) (err error) {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	pc, err := w.pool.get()
+	if err != nil {
+		return fmt.Errorf("get connection error %v", err)
+	}
+	defer func() {
+		if err != nil {
+			pc.discard(w.insertSql)
+		}
+		w.pool.put(pc, err)
+	}()
+
+	stmt, err := w.pool.prepare(pc, w.insertSql)
+	if err != nil {
+		return err
+	}
+	return w.bind(stmt, rows)
+}
+
+// bind 按子表绑定参数并执行
+func (w *stmtWriter) bind(stmt insertStmt, rows []row) error {
+	tables, groups := groupByTable(rows)
+	for _, table := range tables {
+		if len(w.table) == 0 {
+			if len(table) == 0 {
+				return fmt.Errorf("table name of line-[%d] is null", groups[table][0].line)
+			}
+			name, err := db_table.TableIdentifier(w.db, table)
+			if err != nil {
+				return err
+			}
+			if err = stmt.SetTableName(name); err != nil {
+				return fmt.Errorf("set table name [%s] error %v", table, err)
+			}
+		}
+
+		params, err := w.params(groups[table])
+		if err != nil {
+			return fmt.Errorf("parse params error %v", err)
+		}
+		if err = stmt.BindParam(params, w.columnTypes); err != nil {
+			return fmt.Errorf("bind params error %s %v", table, err)
+		}
+		if err = stmt.AddBatch(); err != nil {
+			return fmt.Errorf("add batch error %v", err)
+		}
+	}
+
+	if err := stmt.Execute(); err != nil {
+		return fmt.Errorf("insert data error %v", err)
+	}
+	return nil
+}
+
+// stmtSql table 为空时子表名在 bind 时按 row.table 设置，表名和 SQL 写入一样带库名并转义
+func (w *stmtWriter) stmtSql() (string, error) {
+	var buffer bytes.Buffer
+	if len(w.table) == 0 {
+		buffer.WriteString("insert into ? values (")
+	} else {
+		table, err := db_table.TableIdentifier(w.db, w.table)
+		if err != nil {
+			return "", err
+		}
+		buffer.WriteString(fmt.Sprintf("insert into %s values (", table))
+	}
+	for range w.columns {
+		buffer.WriteString("?, ")
+	}
+	buffer.Truncate(buffer.Len() - 2)
+	buffer.WriteString(")")
+	return buffer.String(), nil
+}
+
+func (w *stmtWriter) columnType() (*param.ColumnType, error) {
+	columnType := param.NewColumnType(len(w.columns))
+
+	for _, column := range w.columns {
+		t := common.BaseType(column.Type)
+		switch t {
+		case common.TypeTimeStamp:
+			columnType.AddTimestamp()
+		case common.TypeTinyInt:
+			columnType.AddTinyint()
+		case common.TypeTinyIntUnsigned:
+			columnType.AddUTinyint()
+		case common.TypeSmallInt:
+			columnType.AddSmallint()
+		case common.TypeSmallIntUnSigned:
+			columnType.AddUSmallint()
+		case common.TypeInt:
+			columnType.AddInt()
+		case common.TypeIntUnSigned:
+			columnType.AddUInt()
+		case common.TypeBigInt:
+			columnType.AddBigint()
+		case common.TypeBigIntUnsigned:
+			columnType.AddUBigint()
+		case common.TypeFloat:
+			columnType.AddFloat()
+		case common.TypeDouble:
+			columnType.AddDouble()
+		case common.TypeBool:
+			columnType.AddBool()
+		case common.TypeBinary, common.TypeVarchar, common.TypeVarbinary, common.TypeGeometry:
+			// driver-go 没有 varbinary 和 geometry 的绑定类型，按 binary 绑定
+			length, err := getFieldLength(column.Type, t)
+			if err != nil {
+				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+			}
+			columnType.AddBinary(length)
+		case common.TypeNchar:
+			length, err := getFieldLength(column.Type, t)
+			if err != nil {
+				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+			}
+			columnType.AddNchar(length)
+		case common.TypeJson:
+			length, err := getFieldLength(column.Type, t)
+			if err != nil {
+				return nil, fmt.Errorf("column %s type error. %v", column.Field, err)
+			}
+			columnType.AddJson(length)
+		default:
+			return nil, fmt.Errorf("column %s unknown type [%s]", column.Field, column.Type)
+		}
+	}
+
+	return columnType, nil
+}
+
+// params 按列转换并绑定，转换失败时返回的 error 包含行号和列名
+func (w *stmtWriter) params(rows []row) (params []*param.Param, err error) {
+	params = make([]*param.Param, 0, len(w.columns))
+
+	for i, column := range w.columns {
+		t := common.BaseType(column.Type)
+		p := param.NewParam(len(rows))
+		for _, r := range rows {
+			value, err := common.Convert(r.values[i], t, w.timeUnit)
+			if err != nil {
+				return nil, fmt.Errorf("line-[%d] column-[%s] %v", r.line, column.Field, err)
+			}
+			if value == nil {
+				p.AddNull()
+				continue
+			}
+
+			switch v := value.(type) {
+			case int64:
+				switch t {
+				case common.TypeTinyInt:
+					p.AddTinyint(int(v))
+				case common.TypeSmallInt:
+					p.AddSmallint(int(v))
+				case common.TypeInt:
+					p.AddInt(int(v))
+				default:
+					p.AddBigint(int(v))
+				}
+			case uint64:
+				switch t {
+				case common.TypeTinyIntUnsigned:
+					p.AddUTinyint(uint(v))
+				case common.TypeSmallIntUnSigned:
+					p.AddUSmallint(uint(v))
+				case common.TypeIntUnSigned:
+					p.AddUInt(uint(v))
+				default:
+					p.AddUBigint(uint(v))
+				}
+			case time.Time:
+				p.AddTimestamp(v, w.precision)
+			case float32:
+				p.AddFloat(v)
+			case float64:
+				p.AddDouble(v)
+			case bool:
+				p.AddBool(v)
+			case []byte:
+				p.AddBinary(v)
+			case string:
+				if t == common.TypeJson {
+					p.AddJson([]byte(v))
+				} else {
+					p.AddNchar(v)
+				}
+			}
+		}
+		params = append(params, p)
+	}
+
+	return
+}
+
+// fieldLengthPattern 类型名后括号内的长度，如 VARCHAR(10)、nchar( 20 )
+var fieldLengthPattern = regexp.MustCompile(`^[^(]+\(\s*(\d+)\s*\)\s*$`)
+
+// getFieldLength 返回 fieldType 括号内的长度，类型名不区分大小写
+func getFieldLength(fieldType string, baseType string) (int, error) {
+	m := fieldLengthPattern.FindStringSubmatch(fieldType)
+	if m == nil || common.BaseType(fieldType) != baseType {
+		return 0, fmt.Errorf("no length in type [%s]", fieldType)
+	}
+	return strconv.Atoi(m[1])
+}
+
+func dbPrecision(p string) int {
+	if p == "ns" {
+		return common2.PrecisionNanoSecond
+	}
+	if p == "us" {
+		return common2.PrecisionMicroSecond
+	}
+	return common2.PrecisionMilliSecond
+}
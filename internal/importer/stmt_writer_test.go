@@ -0,0 +1,63 @@
+package importer
+
+import (
+	"taos_importer/internal/config"
+	"testing"
+)
+
+func TestGetFieldLength(t *testing.T) {
+	cases := []struct {
+		name      string
+		fieldType string
+		baseType  string
+		expect    int
+		err       bool
+	}{
+		{name: "lower", fieldType: "varchar(10)", baseType: "varchar", expect: 10},
+		{name: "upper", fieldType: "VARCHAR(10)", baseType: "varchar", expect: 10},
+		{name: "spaces", fieldType: "NChar( 20 ) ", baseType: "nchar", expect: 20},
+		{name: "binary", fieldType: "BINARY(64)", baseType: "binary", expect: 64},
+		{name: "no length", fieldType: "varchar", baseType: "varchar", err: true},
+		{name: "not a number", fieldType: "varchar(a)", baseType: "varchar", err: true},
+		{name: "other type", fieldType: "nchar(10)", baseType: "varchar", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			length, err := getFieldLength(c.fieldType, c.baseType)
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if length != c.expect {
+				t.Fatalf("length error. expect-[%d] but got-[%d]", c.expect, length)
+			}
+		})
+	}
+}
+
+func TestStmtWriter_StmtSql(t *testing.T) {
+	columns := []config.Column{{Field: "ts", Type: "timestamp"}, {Field: "price", Type: "double"}}
+	cases := []struct {
+		name   string
+		table  string
+		expect string
+		err    bool
+	}{
+		{name: "by row", expect: "insert into ? values (?, ?)"},
+		{name: "reserved word", table: "order", expect: "insert into `stock`.`order` values (?, ?)"},
+		{name: "mixed case", table: "T_Quote", expect: "insert into `stock`.`T_Quote` values (?, ?)"},
+		{name: "illegal", table: "t`1", err: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w, err := newStmtWriter(nil, "stock", c.table, columns, "ms")
+			if (err != nil) != c.err {
+				t.Fatalf("error %v, expect error %v", err, c.err)
+			}
+			if w.insertSql != c.expect {
+				t.Fatalf("expect-[%s] but got-[%s]", c.expect, w.insertSql)
+			}
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package importer
+
+import (
+	"context"
+	"time"
+)
+
+// Tail 消费主题，按子表统计写入 TDengine 的行数，不写入数据
+type Tail struct {
+	*Rates
+	consumer Consumer
+}
+
+func NewTail(consumer Consumer, start time.Time) *Tail {
+	return &Tail{Rates: NewRates(start), consumer: consumer}
+}
+
+// Poll 消费一次并提交，返回消费的行数
+func (t *Tail) Poll(ctx context.Context, timeout time.Duration) (int, error) {
+	message, err := t.consumer.Poll(timeout)
+	if err != nil || message == nil {
+		return 0, err
+	}
+	n := 0
+	for _, table := range message.Tables {
+		t.add(table.Table, len(table.Rows))
+		n += len(table.Rows)
+	}
+	return n, t.consumer.Commit(message)
+}
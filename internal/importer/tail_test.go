@@ -0,0 +1,98 @@
+package importer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeConsumer 依次返回 messages，之后返回 nil，记录提交的消息
+type fakeConsumer struct {
+	messages  []*Message
+	committed []uint64
+}
+
+func (c *fakeConsumer) Subscribe(_ []string) error {
+	return nil
+}
+
+func (c *fakeConsumer) Poll(_ time.Duration) (*Message, error) {
+	if len(c.messages) == 0 {
+		return nil, nil
+	}
+	m := c.messages[0]
+	c.messages = c.messages[1:]
+	return m, nil
+}
+
+func (c *fakeConsumer) Commit(message *Message) error {
+	c.committed = append(c.committed, message.ID)
+	return nil
+}
+
+func (c *fakeConsumer) Close() error {
+	return nil
+}
+
+func TestTail_Poll(t *testing.T) {
+	start := time.Date(2022, 11, 23, 0, 0, 0, 0, time.UTC)
+	consumer := &fakeConsumer{messages: []*Message{
+		{ID: 1, Tables: []TableRows{{Table: "t_b", Rows: [][]any{{1}, {2}}}, {Table: "t_a", Rows: [][]any{{1}}}}},
+		{ID: 2},
+		{ID: 3, Tables: []TableRows{{Table: "t_b", Rows: [][]any{{3}, {4}}}}},
+	}}
+	tail := NewTail(consumer, start)
+
+	cases := []struct {
+		name   string
+		polls  int
+		rows   int
+		rates  []TableRate
+		commit []uint64
+	}{
+		{
+			name:   "first",
+			polls:  2,
+			rows:   3,
+			rates:  []TableRate{{Table: "t_a", Rows: 1, PerSecond: 0.5}, {Table: "t_b", Rows: 2, PerSecond: 1}},
+			commit: []uint64{1, 2},
+		},
+		{
+			name:   "since last take",
+			polls:  2,
+			rows:   2,
+			rates:  []TableRate{{Table: "t_b", Rows: 2, PerSecond: 1}},
+			commit: []uint64{1, 2, 3},
+		},
+		{
+			name:   "no message",
+			polls:  1,
+			rates:  []TableRate{},
+			commit: []uint64{1, 2, 3},
+		},
+	}
+
+	for i, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rows := 0
+			for j := 0; j < c.polls; j++ {
+				n, err := tail.Poll(context.Background(), time.Second)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rows += n
+			}
+			if rows != c.rows {
+				t.Fatalf("rows error. expect-[%d] but got-[%d]", c.rows, rows)
+			}
+			rates := tail.Take(start.Add(time.Duration(i+1) * 2 * time.Second))
+			if !reflect.DeepEqual(rates, c.rates) {
+				t.Fatalf("rates error. expect-%v but got-%v", c.rates, rates)
+			}
+			if !reflect.DeepEqual(consumer.committed, c.commit) {
+				t.Fatalf("commit error. expect-%v but got-%v", c.commit, consumer.committed)
+			}
+		})
+	}
+}
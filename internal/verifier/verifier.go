@@ -0,0 +1,128 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"taos_importer/internal/db_table"
+	"taos_importer/internal/importer"
+	"time"
+)
+
+// sumTolerance sum 的相对误差，float 列在 TDengine 中按 float32 存储
+const sumTolerance = 1e-6
+
+// Target 核对的目标，DatabaseAndTable 查询 TDengine
+type Target interface {
+	TableSummary(ctx context.Context, param db_table.SummaryParam) (db_table.Summary, error)
+}
+
+// Verifier 按子表核对源文件的统计和 TDengine 中的数据：不同时间戳的行数、第一个和最后一个时间戳、列的和。
+// 只查询源文件时间戳范围内的数据，范围外已有的数据不影响结果
+type Verifier struct {
+	target  Target
+	workers int
+
+	Tables     atomic.Int64
+	Mismatched atomic.Int64
+	Failed     atomic.Int64
+}
+
+func NewVerifier(target Target, workers int) *Verifier {
+	if workers <= 0 {
+		workers = 1
+	}
+	return &Verifier{target: target, workers: workers}
+}
+
+// Verify 核对所有子表，返回每个子表的结果，顺序与 expected 相同
+func (v *Verifier) Verify(ctx context.Context, expected []importer.TableStats) []string {
+	results := make([]string, len(expected))
+	ch := make(chan int)
+	var wait sync.WaitGroup
+	go func() {
+		defer close(ch)
+		for i := range expected {
+			ch <- i
+		}
+	}()
+	for i := 0; i < v.workers; i++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for i := range ch {
+				results[i] = v.verifyTable(ctx, expected[i])
+			}
+		}()
+	}
+	wait.Wait()
+	return results
+}
+
+func (v *Verifier) verifyTable(ctx context.Context, expected importer.TableStats) string {
+	v.Tables.Add(1)
+	sums := make([]string, 0, len(expected.Sums))
+	for column := range expected.Sums {
+		sums = append(sums, column)
+	}
+	sort.Strings(sums)
+
+	summary, err := v.target.TableSummary(ctx, db_table.SummaryParam{
+		DBName:    expected.DB,
+		TableName: expected.Table,
+		TsColumn:  expected.TsColumn,
+		Sums:      sums,
+		From:      expected.First,
+		To:        expected.Last,
+	})
+	if err != nil {
+		v.Failed.Add(1)
+		return fmt.Sprintf("## verify table [%s] error %v", expected.Table, err)
+	}
+
+	diff := compare(expected, summary, sums)
+	if len(diff) > 0 {
+		v.Mismatched.Add(1)
+		return fmt.Sprintf("## verify table [%s] mismatch. %s", expected.Table, strings.Join(diff, " "))
+	}
+	return fmt.Sprintf("## verify table [%s] ok. rows-[%d] first-[%s] last-[%s]", expected.Table, expected.Rows, format(&expected.First), format(&expected.Last))
+}
+
+// compare 返回不同的项，每项为 name source-[..] tdengine-[..]
+func compare(expected importer.TableStats, summary db_table.Summary, sums []string) []string {
+	var diff []string
+	if expected.Rows != summary.Rows {
+		diff = append(diff, fmt.Sprintf("rows source-[%d] tdengine-[%d]", expected.Rows, summary.Rows))
+	}
+	if summary.First == nil || !summary.First.Equal(expected.First) {
+		diff = append(diff, fmt.Sprintf("first source-[%s] tdengine-[%s]", format(&expected.First), format(summary.First)))
+	}
+	if summary.Last == nil || !summary.Last.Equal(expected.Last) {
+		diff = append(diff, fmt.Sprintf("last source-[%s] tdengine-[%s]", format(&expected.Last), format(summary.Last)))
+	}
+	for _, column := range sums {
+		var actual float64
+		if summary.Sums[column] != nil {
+			actual = *summary.Sums[column]
+		}
+		if !equalSum(expected.Sums[column], actual) {
+			diff = append(diff, fmt.Sprintf("sum(%s) source-[%v] tdengine-[%v]", column, expected.Sums[column], actual))
+		}
+	}
+	return diff
+}
+
+func equalSum(a, b float64) bool {
+	return math.Abs(a-b) <= sumTolerance*math.Max(1, math.Max(math.Abs(a), math.Abs(b)))
+}
+
+func format(t *time.Time) string {
+	if t == nil {
+		return "NULL"
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,63 @@
+package verifier
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"taos_importer/internal/db_table"
+	"taos_importer/internal/importer"
+	"testing"
+	"time"
+)
+
+// fakeTarget 按子表返回固定的统计，没有的子表返回错误
+type fakeTarget map[string]db_table.Summary
+
+func (f fakeTarget) TableSummary(_ context.Context, param db_table.SummaryParam) (db_table.Summary, error) {
+	summary, ok := f[param.TableName]
+	if !ok {
+		return summary, fmt.Errorf("table does not exist")
+	}
+	if !reflect.DeepEqual(param.Sums, []string{"price", "volume"}) {
+		return summary, fmt.Errorf("sums %v", param.Sums)
+	}
+	return summary, nil
+}
+
+func TestVerifier_Verify(t *testing.T) {
+	first := time.Date(2022, 11, 23, 1, 46, 25, 0, time.UTC)
+	last := first.Add(time.Minute)
+	other := last.Add(time.Second)
+	price, volume, float := 7.5, 40.0, 7.5000001
+	sums := map[string]float64{"price": 7.5, "volume": 40}
+	expected := []importer.TableStats{
+		{DB: "stock", Table: "t_1", TsColumn: "ts", Rows: 2, First: first, Last: last, Sums: sums},
+		{DB: "stock", Table: "t_2", TsColumn: "ts", Rows: 2, First: first, Last: last, Sums: sums},
+		{DB: "stock", Table: "t_3", TsColumn: "ts", Rows: 2, First: first, Last: last, Sums: sums},
+		{DB: "stock", Table: "t_4", TsColumn: "ts", Rows: 2, First: first, Last: last, Sums: sums},
+		{DB: "stock", Table: "t_5", TsColumn: "ts", Rows: 2, First: first, Last: last, Sums: sums},
+	}
+	target := fakeTarget{
+		"t_1": {Rows: 2, First: &first, Last: &last, Sums: map[string]*float64{"price": &float, "volume": &volume}},
+		"t_2": {Rows: 1, First: &first, Last: &other, Sums: map[string]*float64{"price": &price, "volume": &volume}},
+		"t_3": {Rows: 2, First: &first, Last: &last, Sums: map[string]*float64{"price": &volume, "volume": nil}},
+		"t_4": {Rows: 0, Sums: map[string]*float64{}},
+	}
+
+	v := NewVerifier(target, 2)
+	results := v.Verify(context.Background(), expected)
+	expect := []string{
+		"## verify table [t_1] ok. rows-[2] first-[2022-11-23T01:46:25Z] last-[2022-11-23T01:47:25Z]",
+		"## verify table [t_2] mismatch. rows source-[2] tdengine-[1] last source-[2022-11-23T01:47:25Z] tdengine-[2022-11-23T01:47:26Z]",
+		"## verify table [t_3] mismatch. sum(price) source-[7.5] tdengine-[40] sum(volume) source-[40] tdengine-[0]",
+		"## verify table [t_4] mismatch. rows source-[2] tdengine-[0] first source-[2022-11-23T01:46:25Z] tdengine-[NULL] " +
+			"last source-[2022-11-23T01:47:25Z] tdengine-[NULL] sum(price) source-[7.5] tdengine-[0] sum(volume) source-[40] tdengine-[0]",
+		"## verify table [t_5] error table does not exist",
+	}
+	if !reflect.DeepEqual(results, expect) {
+		t.Errorf("\n%v\nexpect\n%v", results, expect)
+	}
+	if v.Tables.Load() != 5 || v.Mismatched.Load() != 3 || v.Failed.Load() != 1 {
+		t.Errorf("tables %d mismatched %d failed %d", v.Tables.Load(), v.Mismatched.Load(), v.Failed.Load())
+	}
+}
@@ -0,0 +1,216 @@
+package watcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	StatusDone   = "done"
+	StatusFailed = "failed"
+)
+
+// Options 监视 Dir 下后缀为 Suffix 的文件，处理过的文件记录在 StateFile，
+// DoneDir、FailedDir 不为空时把成功、失败的文件移动到这两个目录，相对路径是相对 Dir 的路径
+type Options struct {
+	Dir       string
+	Suffix    string
+	StateFile string
+	DoneDir   string
+	FailedDir string
+}
+
+// FileState 一个处理过的文件，文件的大小或修改时间变化后再次处理
+type FileState struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+	Status  string    `json:"status"`
+	Time    time.Time `json:"time"`
+	Moved   string    `json:"moved,omitempty"`
+	Error   string    `json:"error,omitempty"`
+}
+
+type fileInfo struct {
+	size    int64
+	modTime time.Time
+}
+
+// Watcher 轮询目录发现新文件。两次轮询之间大小和修改时间都没有变化的文件认为已经写完。
+// DoneDir、FailedDir 和 StateFile 不被监视，Poll 不能并发调用
+type Watcher struct {
+	options Options
+	exclude []string
+	seen    map[string]fileInfo // 上一次轮询时未处理的文件
+	mu      sync.Mutex
+	state   map[string]FileState
+}
+
+// New 读取 StateFile 中已处理的文件，StateFile 不存在时从空开始
+func New(options Options) (*Watcher, error) {
+	if len(options.Dir) == 0 {
+		return nil, fmt.Errorf("watch dir is null")
+	}
+	if len(options.StateFile) == 0 {
+		return nil, fmt.Errorf("state file is null")
+	}
+	var err error
+	if options.Dir, err = filepath.Abs(options.Dir); err != nil {
+		return nil, err
+	}
+	if options.StateFile, err = filepath.Abs(options.StateFile); err != nil {
+		return nil, err
+	}
+	options.DoneDir = resolve(options.Dir, options.DoneDir)
+	options.FailedDir = resolve(options.Dir, options.FailedDir)
+
+	w := &Watcher{options: options, seen: make(map[string]fileInfo), state: make(map[string]FileState)}
+	for _, p := range []string{options.StateFile, options.StateFile + ".tmp", options.DoneDir, options.FailedDir} {
+		if len(p) > 0 {
+			w.exclude = append(w.exclude, p)
+		}
+	}
+	b, err := os.ReadFile(options.StateFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return w, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &w.state); err != nil {
+		return nil, fmt.Errorf("read state file [%s] error %v", options.StateFile, err)
+	}
+	return w, nil
+}
+
+func resolve(dir string, p string) string {
+	if len(p) == 0 || filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(dir, p)
+}
+
+// Poll 返回已经写完且未处理的文件，按文件名排序
+func (w *Watcher) Poll() ([]string, error) {
+	current := make(map[string]fileInfo)
+	err := filepath.WalkDir(w.options.Dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// 轮询期间被删除的文件忽略
+			if errors.Is(err, fs.ErrNotExist) && p != w.options.Dir {
+				return nil
+			}
+			return err
+		}
+		if w.excluded(p) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), w.options.Suffix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		f := fileInfo{size: info.Size(), modTime: info.ModTime()}
+		if !w.processed(p, f) {
+			current[p] = f
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var ready []string
+	for p, f := range current {
+		if last, ok := w.seen[p]; ok && last.size == f.size && last.modTime.Equal(f.modTime) {
+			ready = append(ready, p)
+		}
+	}
+	w.seen = current
+	sort.Strings(ready)
+	return ready, nil
+}
+
+func (w *Watcher) excluded(p string) bool {
+	for _, e := range w.exclude {
+		if p == e {
+			return true
+		}
+	}
+	return false
+}
+
+// processed 文件已处理且之后没有变化
+func (w *Watcher) processed(p string, f fileInfo) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	s, ok := w.state[p]
+	return ok && s.Size == f.size && s.ModTime.Equal(f.modTime)
+}
+
+// Done 记录文件的处理结果并写入 StateFile，配置了 DoneDir 或 FailedDir 时移动文件，返回移动后的路径
+func (w *Watcher) Done(file string, result error) (string, error) {
+	info, err := os.Stat(file)
+	if err != nil {
+		return "", err
+	}
+	s := FileState{Size: info.Size(), ModTime: info.ModTime(), Status: StatusDone, Time: time.Now()}
+	dir := w.options.DoneDir
+	if result != nil {
+		s.Status, s.Error = StatusFailed, result.Error()
+		dir = w.options.FailedDir
+	}
+	if len(dir) > 0 {
+		if s.Moved, err = w.move(file, dir); err != nil {
+			return "", err
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.state[file] = s
+	return s.Moved, w.save()
+}
+
+// move 把文件移动到 dir 下相同的相对路径，已有的文件被覆盖
+func (w *Watcher) move(file string, dir string) (string, error) {
+	rel, err := filepath.Rel(w.options.Dir, file)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(dir, rel)
+	if err = os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return "", err
+	}
+	if err = os.Rename(file, target); err != nil {
+		return "", err
+	}
+	return target, nil
+}
+
+// save 先写入临时文件再重命名，中断时不会留下不完整的 StateFile
+func (w *Watcher) save() error {
+	b, err := json.MarshalIndent(w.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := w.options.StateFile + ".tmp"
+	if err = os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.options.StateFile)
+}
@@ -0,0 +1,170 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, file string, data string, modTime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(file, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatcher_Poll(t *testing.T) {
+	modTime := time.Date(2022, 11, 23, 15, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name   string
+		change func(t *testing.T, dir string, poll int) // 第 poll 次轮询前修改文件
+		polls  int
+		expect [][]string // 每次轮询返回的文件，相对 dir
+	}{
+		{
+			name: "stable after two polls",
+			change: func(t *testing.T, dir string, poll int) {
+				if poll == 0 {
+					writeFile(t, filepath.Join(dir, "a.csv"), "ts\n1\n", modTime)
+					writeFile(t, filepath.Join(dir, "sub", "b.csv"), "ts\n1\n", modTime)
+					writeFile(t, filepath.Join(dir, "c.txt"), "ts\n1\n", modTime)
+				}
+			},
+			polls:  3,
+			expect: [][]string{nil, {"a.csv", "sub/b.csv"}, {"a.csv", "sub/b.csv"}},
+		},
+		{
+			name: "growing file",
+			change: func(t *testing.T, dir string, poll int) {
+				if poll < 2 {
+					writeFile(t, filepath.Join(dir, "a.csv"), "ts\n1\n"+string(rune('0'+poll)), modTime.Add(time.Duration(poll)*time.Second))
+				}
+			},
+			polls:  4,
+			expect: [][]string{nil, nil, {"a.csv"}, {"a.csv"}},
+		},
+		{
+			name: "rewritten with same size",
+			change: func(t *testing.T, dir string, poll int) {
+				if poll < 2 {
+					writeFile(t, filepath.Join(dir, "a.csv"), "ts\n1\n", modTime.Add(time.Duration(poll)*time.Second))
+				}
+			},
+			polls:  3,
+			expect: [][]string{nil, nil, {"a.csv"}},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			w, err := New(Options{Dir: dir, Suffix: ".csv", StateFile: filepath.Join(dir, "state.json")})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < c.polls; i++ {
+				c.change(t, dir, i)
+				files, err := w.Poll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				var rel []string
+				for _, f := range files {
+					r, _ := filepath.Rel(dir, f)
+					rel = append(rel, filepath.ToSlash(r))
+				}
+				if !reflect.DeepEqual(rel, c.expect[i]) {
+					t.Fatalf("poll-[%d] error. expect-%v but got-%v", i, c.expect[i], rel)
+				}
+			}
+		})
+	}
+}
+
+func TestWatcher_Done(t *testing.T) {
+	modTime := time.Date(2022, 11, 23, 15, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name      string
+		doneDir   string
+		failedDir string
+		moved     []string // a.csv、sub/b.csv 移动后的路径，相对 dir，为空时不移动
+	}{
+		{name: "record only", moved: []string{"", ""}},
+		{name: "move", doneDir: "done", failedDir: "failed", moved: []string{"done/a.csv", "failed/sub/b.csv"}},
+		{name: "move done only", doneDir: "done", moved: []string{"done/a.csv", ""}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			options := Options{Dir: dir, Suffix: ".csv", StateFile: filepath.Join(dir, "state.json"), DoneDir: c.doneDir, FailedDir: c.failedDir}
+			w, err := New(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			files := []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "sub", "b.csv")}
+			for _, f := range files {
+				writeFile(t, f, "ts\n1\n", modTime)
+			}
+			for i := 0; i < 2; i++ {
+				if _, err = w.Poll(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, f := range files {
+				var result error
+				if i == 1 {
+					result = errors.New("import error")
+				}
+				moved, err := w.Done(f, result)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var expect string
+				if len(c.moved[i]) > 0 {
+					expect = filepath.Join(dir, c.moved[i])
+				}
+				if moved != expect {
+					t.Fatalf("moved error. expect-[%s] but got-[%s]", expect, moved)
+				}
+			}
+
+			// 重新启动后已处理的文件不再返回，移动后的文件不被监视
+			w, err = New(options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < 2; i++ {
+				ready, err := w.Poll()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(ready) > 0 {
+					t.Fatalf("processed files are ready again %v", ready)
+				}
+			}
+			if w.state[files[0]].Status != StatusDone || w.state[files[1]].Status != StatusFailed || w.state[files[1]].Error != "import error" {
+				t.Fatalf("state error %v", w.state)
+			}
+
+			// 没有移动的文件变化后再次处理
+			if len(c.moved[0]) == 0 {
+				writeFile(t, files[0], "ts\n1\n2\n", modTime.Add(time.Hour))
+				for i := 0; i < 2; i++ {
+					if ready, err := w.Poll(); err != nil || (i == 1 && !reflect.DeepEqual(ready, files[:1])) {
+						t.Fatalf("changed file is not ready. %v %v", ready, err)
+					}
+				}
+			}
+		})
+	}
+}